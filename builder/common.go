@@ -25,6 +25,8 @@ import (
 	"github.com/otiai10/gosseract/v2"
 
 	"logit/models"
+	"logit/parser"
+	"logit/units"
 )
 
 func FindCommonUnit(portions []Portion, unit string) int  {
@@ -37,7 +39,206 @@ func FindCommonUnit(portions []Portion, unit string) int  {
 }
 
 func IsSameUnit(portion Portion, unit string) bool {
-    return portion.UnitName == unit || portion.AbbrUnitName == unit;
+    // "tablespoons" is the same as "tbsp"
+    unit = units.Normalize(unit)
+    return PortionUnit(portion) == unit || units.Normalize(portion.AbbrUnitName) == unit;
+}
+
+func PortionUnit(portion Portion) string {
+    // USDA units can have notes, "cup, chopped" or "medium (2-1/2" dia)"
+    name := strings.FieldsFunc(portion.UnitName, func(r rune) bool {
+        return r == ',' || r == '('
+    })
+    if len(name) == 0 {
+        return units.Normalize(portion.UnitName)
+    }
+
+    // or without punctuation, "tbsp chopped"
+    words := strings.Fields(name[0])
+    if _, ok := units.Lookup(name[0]); !ok && len(words) > 1 {
+        if _, ok := units.Lookup(words[0]); ok {
+            return units.Normalize(words[0])
+        }
+    }
+
+    return units.Normalize(name[0])
+}
+
+func PortionGrams(portions []Portion, value float32, unit string) (float32, *Portion, bool) {
+    // REQUIRES:    portions of a food, unit
+    // MODIFIES:    none
+    // EFFECTS:     Returns what [value] [unit] of the food weighs and the
+    //              portion it was weighed with. Masses convert to grams
+    //              without a portion. A portion in the same unit is used as
+    //              is, and other volumes are converted into any volume portion,
+    //              so a tbsp of flour is a 16th of its cup. False if none fit
+
+    if grams, ok := units.ToGrams(float64(value), unit); ok {
+        return float32(grams), nil, true
+    }
+
+    if i := FindCommonUnit(portions, unit); i != -1 && portions[i].Amount > 0 {
+        portion := portions[i]
+        return (value / portion.Amount) * portion.GramWeight, &portion, true
+    }
+
+    if !units.IsVolume(unit) {
+        return 0, nil, false
+    }
+
+    for _, portion := range(portions) {
+        if !units.IsVolume(PortionUnit(portion)) || portion.Amount <= 0 {
+            continue
+        }
+
+        amount, ok := units.Convert(float64(value), unit, PortionUnit(portion))
+        if ok {
+            portion := portion
+            return (float32(amount) / portion.Amount) * portion.GramWeight, &portion, true
+        }
+    }
+
+    return 0, nil, false
+}
+
+// what a food counted without a size is portioned by, in order
+var defaultCountPortions = []string{"medium", "whole", "each", "piece", "large", "small"}
+
+func IsCounted(unit string) bool {
+    // "2 eggs" and "1 piece of chicken" don't say how much one is
+    unit = units.Normalize(unit)
+    return unit == "" || unit == parser.UNIT_WHOLE || unit == "each" || unit == "piece"
+}
+
+func CountUnit(name, modifier string) string {
+    // REQUIRES:    name of a counted ingredient
+    // MODIFIES:    none
+    // EFFECTS:     Returns what the ingredient is counted in when its amount
+    //              doesn't say, for CountGrams. That's a size before or after
+    //              the food, "large" of "large eggs", "eggs, large" or the
+    //              modifier "large", or a count unit after it, "clove" of
+    //              "garlic cloves". Empty if neither says
+
+    if size, _ := units.SplitSize(name); size != "" {
+        return size
+    }
+
+    first, _, _ := strings.Cut(modifier, ",")
+    if size, rest := units.SplitSize(first); size != "" && rest == "" {
+        return size
+    }
+
+    words := strings.Fields(name)
+    if len(words) > 1 {
+        if unit, ok := units.Lookup(words[len(words)-1]); ok && unit.Kind == units.COUNT {
+            return unit.Name
+        }
+    }
+
+    return ""
+}
+
+func CountGrams(portions []Portion, value float32, count string) (float32, *Portion, bool) {
+    // REQUIRES:    portions of a food
+    // MODIFIES:    none
+    // EFFECTS:     Returns what [value] of the food weighs when it's counted,
+    //              "2 large eggs", and the portion it was weighed with. The
+    //              portion of [count], a size or a count unit from CountUnit,
+    //              is used if the food has one, then the defaultCountPortions,
+    //              then whatever portion isn't a mass or volume, an egg or a
+    //              fruit. False if the food only has those
+
+    find := func(unit string) *Portion {
+        for _, portion := range(portions) {
+            if PortionUnit(portion) == unit && portion.Amount > 0 {
+                portion := portion
+                return &portion
+            }
+        }
+        return nil
+    }
+
+    var portion *Portion
+    if count != "" {
+        portion = find(count)
+    }
+    for i := 0; portion == nil && i < len(defaultCountPortions); i++ {
+        portion = find(defaultCountPortions[i])
+    }
+    for i := 0; portion == nil && i < len(portions); i++ {
+        unit := PortionUnit(portions[i])
+        if !units.IsMass(unit) && !units.IsVolume(unit) && portions[i].Amount > 0 {
+            candidate := portions[i]
+            portion = &candidate
+        }
+    }
+
+    if portion == nil {
+        return 0, nil, false
+    }
+
+    return (value / portion.Amount) * portion.GramWeight, portion, true
+}
+
+func AmountGrams(portions []Portion, ingredient models.Ingredient) (float32, *Portion, bool) {
+    // REQUIRES:    portions of the food, ingredient with amounts
+    // MODIFIES:    none
+    // EFFECTS:     Returns what the ingredient weighs and the portion it was
+    //              weighed with. The first amount is used if it can be
+    //              weighed, plus the ones added to it, "2 lb 4 oz". Otherwise
+    //              the size of each, "2 (15 oz) cans" are 30 oz, or else the
+    //              first alternative that can be, "1 cup (240 ml)". Amounts
+    //              without a Relation are sizes of counts like cans and
+    //              alternatives of anything else
+
+    weigh := func(amount models.Amount) (float32, *Portion, bool) {
+        grams, portion, ok := PortionGrams(portions, amount.Value, amount.Unit)
+        if !ok && IsCounted(amount.Unit) {
+            // "2 large eggs" and "3 garlic cloves", what they're counted in
+            // is part of the name
+            grams, portion, ok = CountGrams(portions, amount.Value, CountUnit(ingredient.Name, ingredient.Modifier))
+        }
+        return grams, portion, ok
+    }
+
+    first := ingredient.Amounts[0]
+    relations := make([]string, len(ingredient.Amounts))
+    for i, amount := range ingredient.Amounts[1:] {
+        relations[i+1] = amount.Relation
+        if amount.Relation == "" {
+            relations[i+1] = models.AMOUNT_ALTERNATIVE
+            if unit, ok := units.Lookup(first.Unit); ok && unit.Kind == units.COUNT {
+                relations[i+1] = models.AMOUNT_PER_UNIT
+            }
+        }
+    }
+
+    if grams, portion, ok := weigh(first); ok {
+        for i, amount := range ingredient.Amounts {
+            if relations[i] != models.AMOUNT_ADDITIVE {
+                continue
+            }
+            if more, _, ok := weigh(amount); ok {
+                grams += more
+            }
+        }
+        return grams, portion, true
+    }
+
+    for i, amount := range ingredient.Amounts {
+        switch relations[i] {
+        case models.AMOUNT_PER_UNIT:
+            if size, portion, ok := weigh(amount); ok {
+                return first.Value * size, portion, true
+            }
+        case models.AMOUNT_ALTERNATIVE:
+            if grams, portion, ok := weigh(amount); ok {
+                return grams, portion, true
+            }
+        }
+    }
+
+    return 0, nil, false
 }
 
 func ComputeNutrientValue(value float32, multiplier float32) float64 {
@@ -66,7 +267,36 @@ func ConstructQueryString(list []string) string {
     return params.Encode()
 }
 
+// which ingredient parser ParseIngredients uses
+const (
+    INGREDIENT_PARSER_REMOTE = "remote"
+    INGREDIENT_PARSER_NATIVE = "native"
+)
+
 func ParseIngredients(r models.IngredientParseRequest) ([]models.Ingredient, error) {
+    // REQUIRES:    r
+    // MODIFIES:    none
+    // EFFECTS:     Parses every line of [r], one models.Ingredient per line.
+    //              INGREDIENT_PARSER=remote only uses the parser service at
+    //              PARSER_API_URL and native only parser.ParseIngredient.
+    //              By default the service is used when it's configured, and
+    //              the native parser when it isn't or the service fails
+
+    mode := strings.ToLower(os.Getenv("INGREDIENT_PARSER"))
+    if mode == INGREDIENT_PARSER_NATIVE || (mode != INGREDIENT_PARSER_REMOTE && os.Getenv("PARSER_API_URL") == "") {
+        return parser.ParseIngredientList(r.List), nil
+    }
+
+    parsed, err := parseIngredientsRemote(r)
+    if err != nil && mode != INGREDIENT_PARSER_REMOTE {
+        log.Printf("[BUILDER] ingredient parser api failed, using the native parser: %+v", err)
+        return parser.ParseIngredientList(r.List), nil
+    }
+
+    return parsed, err
+}
+
+func parseIngredientsRemote(r models.IngredientParseRequest) ([]models.Ingredient, error) {
     // does the http request    
     client := http.Client {
         Timeout: 10 * time.Second,
@@ -79,15 +309,30 @@ func ParseIngredients(r models.IngredientParseRequest) ([]models.Ingredient, err
     
     res, err := client.Do(req)
     if err != nil {
-        fmt.Printf("[BUILDER] http error: %+v", err)
+        log.Printf("[BUILDER] http error: %+v", err)
         return nil, err
     }
+    defer res.Body.Close()
+
+    if res.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("ingredient parser api responded %d", res.StatusCode)
+    }
 
     var apiResponse models.Response[[]models.Ingredient]
-    body, _ := io.ReadAll(res.Body)
-    defer res.Body.Close()
+    body, err := io.ReadAll(res.Body)
+    if err != nil {
+        return nil, err
+    }
 
-    json.Unmarshal(body, &apiResponse)
+    if err := json.Unmarshal(body, &apiResponse); err != nil {
+        log.Printf("[BUILDER] unmarshal error: %+v", err)
+        return nil, err
+    }
+
+    // the results are matched with the lines by their index
+    if len(apiResponse.Data) != len(r.List) {
+        return nil, fmt.Errorf("ingredient parser api parsed %d of %d lines", len(apiResponse.Data), len(r.List))
+    }
 
     // set some security so only this Golang service can access the Parser API 
     // https://security.stackexchange.com/questions/255762/is-this-a-right-technique-to-create-and-validate-session-tokens
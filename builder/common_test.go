@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"math"
+	"testing"
+
+	"logit/parser"
+)
+
+func TestPortionGrams(t *testing.T) {
+    flour := []Portion{
+        {Amount: 1, UnitName: "cup", GramWeight: 125},
+    }
+    garlic := []Portion{
+        {Amount: 1, UnitName: "tsp", GramWeight: 2.8},
+        {Amount: 1, UnitName: "clove", GramWeight: 3},
+    }
+
+    tests := []struct {
+        portions    []Portion
+        value       float32
+        unit        string
+        grams       float32
+        ok          bool
+    }{
+        // masses don't need a portion
+        {nil, 2, "lb", 907.18, true},
+        {flour, 250, "g", 250, true},
+        // a portion in the same unit
+        {flour, 2, "cups", 250, true},
+        {garlic, 2, "cloves", 6, true},
+        // other volumes go through a volume portion
+        {flour, 1, "tbsp", 7.81, true},
+        {flour, 1, "l", 528.34, true},
+        {garlic, 1, "tbsp", 8.4, true},
+        // counts are weighed by CountGrams, not converted
+        {garlic, 2, "whole", 0, false},
+        {flour, 1, "clove", 0, false},
+        {nil, 1, "cup", 0, false},
+    }
+
+    for _, test := range tests {
+        grams, _, ok := PortionGrams(test.portions, test.value, test.unit)
+        if ok != test.ok || math.Abs(float64(grams-test.grams)) > 0.01 {
+            t.Errorf("PortionGrams of %v %q = %v, %v, want %v, %v", test.value, test.unit, grams, ok, test.grams, test.ok)
+        }
+    }
+}
+
+func TestCountGrams(t *testing.T) {
+    garlic := []Portion{
+        {Amount: 1, UnitName: "cup", GramWeight: 136},
+        {Amount: 1, UnitName: "tsp", GramWeight: 2.8},
+        {Amount: 1, UnitName: "clove", GramWeight: 3},
+    }
+    eggs := []Portion{
+        {Amount: 1, UnitName: "cup (4.86 large eggs)", GramWeight: 243},
+        {Amount: 1, UnitName: "extra large", GramWeight: 56},
+        {Amount: 1, UnitName: "large", GramWeight: 50},
+        {Amount: 1, UnitName: "medium", GramWeight: 44},
+    }
+
+    tests := []struct {
+        name        string
+        modifier    string
+        portions    []Portion
+        value       float32
+        count       string
+        grams       float32
+    }{
+        {"garlic cloves", "", garlic, 3, "clove", 9},
+        {"garlic clove", "minced", garlic, 1, "clove", 3},
+        {"large eggs", "", eggs, 2, "large", 100},
+        {"eggs, large", "", eggs, 2, "large", 100},
+        {"eggs", "large, beaten", eggs, 2, "large", 100},
+        {"eggs, extra large", "", eggs, 1, "extra large", 56},
+        // no size is a medium one
+        {"eggs", "beaten", eggs, 2, "", 88},
+    }
+
+    for _, test := range tests {
+        count := CountUnit(test.name, test.modifier)
+        if count != test.count {
+            t.Errorf("CountUnit(%q, %q) = %q, want %q", test.name, test.modifier, count, test.count)
+        }
+
+        grams, _, ok := CountGrams(test.portions, test.value, count)
+        if !ok || math.Abs(float64(grams-test.grams)) > 0.01 {
+            t.Errorf("CountGrams of %v %q = %v, %v, want %v", test.value, test.name, grams, ok, test.grams)
+        }
+    }
+}
+
+func TestAmountGrams(t *testing.T) {
+    tomatoes := []Portion{{Amount: 1, UnitName: "cup", GramWeight: 240}}
+    soySauce := []Portion{{Amount: 1, UnitName: "tbsp", GramWeight: 16}}
+    eggs := []Portion{
+        {Amount: 1, UnitName: "cup", GramWeight: 243},
+        {Amount: 1, UnitName: "large", GramWeight: 50},
+        {Amount: 1, UnitName: "medium", GramWeight: 44},
+    }
+
+    tests := []struct {
+        line        string
+        portions    []Portion
+        grams       float32
+    }{
+        // a can has no portion, its size is weighed instead
+        {"1 (14 oz) can tomatoes", tomatoes, 396.89},
+        {"1 28-ounce can crushed tomatoes", tomatoes, 793.79},
+        {"2 (15 oz) cans beans", nil, 850.49},
+        // the parts add up
+        {"2 lb 4 oz beef", nil, 1020.58},
+        {"1 Tbsp + 1 tsp soy sauce", soySauce, 21.33},
+        // the first amount wins over its alternatives, which are only used
+        // when it can't be weighed
+        {"1 cup (240 ml) tomatoes", tomatoes, 240},
+        {"1 stick (113 g) butter", nil, 113},
+        {"1 cup / 250 g tomatoes", nil, 250},
+        // counts without a portion of their own fall back to a sized one
+        {"2 large eggs", eggs, 100},
+        {"3 eggs, beaten", eggs, 132},
+    }
+
+    for _, test := range tests {
+        ingredient := parser.ParseIngredient(test.line)
+        grams, _, ok := AmountGrams(test.portions, ingredient)
+        if !ok || math.Abs(float64(grams-test.grams)) > 0.01 {
+            t.Errorf("AmountGrams(%q) = %v, %v, want %v", test.line, grams, ok, test.grams)
+        }
+    }
+
+    // the parser service doesn't say how the amounts relate
+    can := parser.ParseIngredient("2 (15 oz) cans beans")
+    for i := range can.Amounts {
+        can.Amounts[i].Relation = ""
+    }
+    if grams, _, ok := AmountGrams(nil, can); !ok || math.Abs(float64(grams-850.49)) > 0.01 {
+        t.Errorf("AmountGrams without relations = %v, %v, want 850.49", grams, ok)
+    }
+
+    if _, _, ok := AmountGrams(nil, parser.ParseIngredient("1 can beans")); ok {
+        t.Errorf("AmountGrams weighed a can without a size")
+    }
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -54,7 +55,14 @@ type Portion struct {
 
 func ConfigureDB() error {
     dsn := os.Getenv("DSN")
-    db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+
+    // DB_DRIVER=sqlite runs on a local file for development, e.g. DSN=logit.db
+    dialector := mysql.Open(dsn)
+    if os.Getenv("DB_DRIVER") == "sqlite" {
+        dialector = sqlite.Open(dsn)
+    }
+
+    db, err := gorm.Open(dialector, &gorm.Config{})
     if (err != nil) {
         return err 
     } 
@@ -64,9 +72,11 @@ func ConfigureDB() error {
 }
 
 func GetFood(food string) Foods {
+    // the best of MatchFoods, an empty Foods if nothing came close
     var usdaFood Foods 
-    wildcard := "%" + food + "%"
-    Db.Where("description LIKE ?", wildcard).First(&usdaFood)
+    if matches := MatchFoods(food, 1); len(matches) > 0 {
+        usdaFood = matches[0].Food
+    }
     return usdaFood
 }
 
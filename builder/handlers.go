@@ -1,9 +1,12 @@
 package builder
 
 import (
+	"io"
 	"log"
 	"logit/models"
+	"logit/parser"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,63 +29,213 @@ func RecipeBuilderHandler() gin.HandlerFunc {
             return
         }
 
-        // if it doesn't follow the form of the recipe, then there will be no amounts
-        // so if there are no amounts, then it can't be an ingreident?
-        result, err := ParseIngredients(req)
-        if err != nil {
-            log.Printf("[BUILDER] ingredient parser api failed")
+        status, res := buildRecipe(req, nil)
+        if status != http.StatusOK {
+            ctx.AbortWithStatusJSON(status, res)
+            return
+        }
+
+        ctx.JSON(status, res)
+    }
+}
+
+func buildRecipe(req models.IngredientParseRequest, progress func(int, string)) (int, interface{}) {
+    // REQUIRES:    req
+    // MODIFIES:    none
+    // EFFECTS:     Builds the nutrition for the ingredient list and returns the
+    //              status and models.Response the builder endpoints respond
+    //              with. [progress] is optional and told about every ingredient
+
+    built, err := BuildNutrition(req, progress)
+    if err != nil {
+        return http.StatusBadRequest, models.Response[interface{}]{
+            Message: "couldn't parse ingredients",
+            Data: nil,
+            Status: http.StatusBadRequest,
+        }
+    }
+
+    return http.StatusOK, models.Response[models.RecipeBuilderResponse]{
+        Message: "recipe built",
+        Data: built,
+        Status: http.StatusOK,
+    }
+}
+
+func BuildNutrition(req models.IngredientParseRequest, progress func(int, string)) (models.RecipeBuilderResponse, error) {
+    // REQUIRES:    req
+    // MODIFIES:    none
+    // EFFECTS:     Parses the ingredient list and adds up the nutrition of
+    //              every ingredient that was found in the database. The lines
+    //              that couldn't be used are returned as errors, the foods
+    //              every ingredient could be as matches, and what every line
+    //              was matched with and added as its breakdown
+
+    if progress == nil {
+        progress = func(int, string) {}
+    }
+
+    // lists pasted by hand or copied from a site have the same entities,
+    // tags and fraction characters as scraped recipes
+    for i, line := range req.List {
+        req.List[i] = parser.SanitizeText(line)
+    }
+
+    // if it doesn't follow the form of the recipe, then there will be no amounts
+    // so if there are no amounts, then it can't be an ingreident?
+    progress(5, "parsing ingredients")
+    result, err := ParseIngredients(req)
+    if err != nil {
+        log.Printf("[BUILDER] ingredient parser api failed")
+        return models.RecipeBuilderResponse{}, err
+    }
+
+    // parse could be successful vs unsucessful. Every line gets a breakdown
+    // so the user sees what happened to it
+    breakdown := make([]models.IngredientBreakdown, 0, len(result))
+    var success []int
+    var exclude []string
+    for i, item := range(result) {
+        line := models.IngredientBreakdown{Text: req.List[i], Ingredient: item}
+
+        // exclude all strings (or lines) where the parse result
+        // didn't find an ingredient name or an amount
+        if item.Name == EMPTY_NAME || len(item.Amounts) == 0 {
+            exclude = append(exclude, req.List[i])
+            line.Error = "no ingredient or amount found"
+        } else {
+            success = append(success, i)
+        }
+        breakdown = append(breakdown, line)
+    }
+    
+    // builds a query to my rustlang service to parse the ingredients
+    // only query the DB on successful parses
+    var recipeNutrition models.Nutrition
+    matched := []models.IngredientMatch{}
+    for n, i := range(success) {
+        item := result[i]
+        line := &breakdown[i]
+        progress(10 + 90*n/len(success), "looking up "+item.Name)
+
+        unit := item.Amounts[0].Unit
+
+        // the best match is used, the others are there for the user to
+        // pick from when it's wrong
+        matches := MatchFoods(item.Name, MAX_FOOD_MATCHES)
+        match := models.IngredientMatch{
+            Ingredient: item.Name,
+            Alternatives: []models.FoodCandidate{},
+        }
+        for j, candidate := range matches {
+            if j == 0 {
+                best := toCandidate(candidate)
+                match.Food = &best
+            } else {
+                match.Alternatives = append(match.Alternatives, toCandidate(candidate))
+            }
+        }
+        matched = append(matched, match)
+
+        if len(matches) == 0 {
+            exclude = append(exclude, item.Name)
+            line.Error = "no matching food found"
+            continue
+        }
+
+        food := matches[0].Food
+        line.FdcId = food.FdcId
+        line.Description = food.Description
+
+        portions := GetAvailablePortions(food.FdcId)
+        servingGramWeight, portion, ok := AmountGrams(portions, item)
+        if ok {
+            multiplier := servingGramWeight / 100 // every food nutrient is for a 100g serving
+            AddFoodNutritionalValue(&recipeNutrition, food, multiplier)
+
+            // masses are weighed without a portion
+            if portion != nil {
+                line.Portion = &models.FoodPortion{
+                    Amount: portion.Amount,
+                    Unit: portion.UnitName,
+                    GramWeight: portion.GramWeight,
+                }
+            }
+            line.GramWeight = float64(servingGramWeight)
+            AddFoodNutritionalValue(&line.Nutrition, food, multiplier)
+        } else {
+            exclude = append(exclude, item.Name);
+            line.Error = "no " + unit + " portion for " + food.Description
+        }
+    } 
+
+    return models.RecipeBuilderResponse{
+        Nutrition: recipeNutrition,
+        Errors: exclude,
+        Matches: matched,
+        Breakdown: breakdown,
+    }, nil
+}
+
+func RecipeTextHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        // accepts a whole recipe pasted as plain text
+        var req models.RecipeTextRequest
+        if err := ctx.BindJSON(&req); err != nil || strings.TrimSpace(req.Text) == "" {
+            log.Printf("[BUILDER] malformed JSON input")
             ctx.AbortWithStatusJSON(http.StatusBadRequest, models.Response[interface{}]{
-                Message: "couldn't parse ingredients",
+                Message: "doesn't follow expected input format",
                 Data: nil,
                 Status: http.StatusBadRequest,
             })
             return
         }
 
-        // parse could be successful vs unsucessful
-        var success []models.Ingredient
-        var exclude []string
-        for i, item := range(result) {
-            // exclude all strings (or lines) where the parse result
-            // didn't find an ingredient name or an amount
-            if item.Name == EMPTY_NAME || len(item.Amounts) == 0 {
-                exclude = append(exclude, req.List[i])
-            } else {
-                success = append(success, item)
-            }
+        status, res := buildTextRecipe(req, nil)
+        if status != http.StatusOK {
+            ctx.AbortWithStatusJSON(status, res)
+            return
         }
-        
-        // builds a query to my rustlang service to parse the ingredients
-        // only query the DB on successful parses
-        var recipeNutrition models.Nutrition
-        for _, item := range(success) {
-            amnt := item.Amounts[0]
-            unit := amnt.Unit
-            value := amnt.Value
-
-            food := GetFood(item.Name);
-            portions := GetAvailablePortions(food.FdcId)
-            commonPortionIdx := FindCommonUnit(portions, unit)
-            if commonPortionIdx != -1 {
-                // do some calculation shit
-                portion := portions[commonPortionIdx]
-                servingGramWeight := (value / portion.Amount) * portion.GramWeight 
-                multiplier := servingGramWeight / 100 // every food nutrient is for a 100g serving
-                AddFoodNutritionalValue(&recipeNutrition, food, multiplier)
-            } else {
-                exclude = append(exclude, item.Name);
-            }
-        } 
-    
-        ctx.JSON(http.StatusOK, models.Response[models.RecipeBuilderResponse]{
-            Message: "recipe built",
-            Data: models.RecipeBuilderResponse{
-                Nutrition: recipeNutrition,
-                Errors: exclude,
-            },
-            Status: http.StatusOK,
-        })
 
+        ctx.JSON(status, res)
+    }
+}
+
+func buildTextRecipe(req models.RecipeTextRequest, progress func(int, string)) (int, interface{}) {
+    // REQUIRES:    req
+    // MODIFIES:    none
+    // EFFECTS:     Splits the text into a recipe and builds the nutrition of
+    //              its ingredients like buildRecipe, divided into the servings
+    //              the text mentions (1 if it doesn't)
+
+    recipe, ok := parser.ParseRecipeText(req.Text)
+    if !ok {
+        return http.StatusUnprocessableEntity, models.Response[interface{}]{
+            Message: "couldn't find any ingredients in the text",
+            Data: nil,
+            Status: http.StatusUnprocessableEntity,
+        }
+    }
+
+    built, err := BuildNutrition(models.IngredientParseRequest{List: recipe.Ingredients}, progress)
+    if err != nil {
+        return http.StatusBadRequest, models.Response[interface{}]{
+            Message: "couldn't parse ingredients",
+            Data: nil,
+            Status: http.StatusBadRequest,
+        }
+    }
+
+    parser.SetTotalNutrition(&recipe, built.Nutrition)
+
+    return http.StatusOK, models.Response[models.RecipeTextResponse]{
+        Message: "recipe built",
+        Data: models.RecipeTextResponse{
+            Recipe: recipe,
+            Errors: built.Errors,
+            Breakdown: built.Breakdown,
+        },
+        Status: http.StatusOK,
     }
 }
 
@@ -109,21 +262,35 @@ func ImageUploadHandler() gin.HandlerFunc {
             return
         }
 
-        ingredientList, err := RunGoogleCloudOCR(file)
-        if err != nil {
-            ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.Response[interface{}]{
-                Message: err.Error(),
-                Data: nil,
-                Status: http.StatusInternalServerError,
-            })
+        status, res := readImage(file)
+        if status != http.StatusOK {
+            ctx.AbortWithStatusJSON(status, res)
             return
         }
-        
-        // return successful response
-        ctx.JSON(http.StatusOK, models.Response[[]string]{
-            Message: "image parsed successfully",
-            Data: ingredientList,
-            Status: http.StatusOK,
-        })
+
+        ctx.JSON(status, res)
+    }
+}
+
+func readImage(file io.Reader) (int, interface{}) {
+    // REQUIRES:    file
+    // MODIFIES:    none
+    // EFFECTS:     Runs OCR on the uploaded image and returns the status and
+    //              models.Response with the ingredient lines it found
+
+    ingredientList, err := RunGoogleCloudOCR(file)
+    if err != nil {
+        return http.StatusInternalServerError, models.Response[interface{}]{
+            Message: err.Error(),
+            Data: nil,
+            Status: http.StatusInternalServerError,
+        }
+    }
+    
+    // return successful response
+    return http.StatusOK, models.Response[[]string]{
+        Message: "image parsed successfully",
+        Data: ingredientList,
+        Status: http.StatusOK,
     }
 }
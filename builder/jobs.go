@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"logit/jobs"
+	"logit/models"
+)
+
+const (
+    BUILD_JOB = "build"
+    OCR_JOB = "ocr"
+    // images are kept in the job until it runs, so they're capped
+    MAX_IMAGE_SIZE = 10 * 1024 * 1024
+)
+
+func RegisterJobs() {
+    // REQUIRES:    none
+    // MODIFIES:    jobs
+    // EFFECTS:     Lets the job workers run the build and OCR jobs submitted
+    //              by RecipeBuilderJobHandler and ImageUploadJobHandler
+
+    jobs.Register(BUILD_JOB, func(payload []byte, progress func(int, string)) (int, interface{}) {
+        var req models.IngredientParseRequest
+        if err := json.Unmarshal(payload, &req); err != nil {
+            return malformedJob()
+        }
+
+        return buildRecipe(req, progress)
+    })
+
+    jobs.Register(OCR_JOB, func(payload []byte, progress func(int, string)) (int, interface{}) {
+        var req models.ImageJobRequest
+        if err := json.Unmarshal(payload, &req); err != nil {
+            return malformedJob()
+        }
+
+        progress(10, "reading image")
+        return readImage(bytes.NewReader(req.Image))
+    })
+}
+
+func malformedJob() (int, interface{}) {
+    return http.StatusBadRequest, models.Response[interface{}]{
+        Message: "doesn't follow expected input format",
+        Data: nil,
+        Status: http.StatusBadRequest,
+    }
+}
+
+func RecipeBuilderJobHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        var req models.IngredientParseRequest
+        if err := ctx.BindJSON(&req); err != nil {
+            log.Printf("[BUILDER] malformed JSON input")
+            ctx.AbortWithStatusJSON(http.StatusBadRequest, models.Response[interface{}]{
+                Message: "doesn't follow expected input format",
+                Data: nil,
+                Status: http.StatusBadRequest,
+            })
+            return
+        }
+
+        jobs.SubmitResponse(ctx, BUILD_JOB, req)
+    }
+}
+
+func ImageUploadJobHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        file, _, err := ctx.Request.FormFile("image")
+        if err != nil {
+            log.Printf("[BUILDER] image upload: %+v", err)
+            ctx.AbortWithStatusJSON(http.StatusBadRequest, models.Response[interface{}]{
+                Message: err.Error(),
+                Data: nil,
+                Status: http.StatusBadRequest,
+            })
+            return
+        }
+        defer file.Close()
+
+        image, err := io.ReadAll(io.LimitReader(file, MAX_IMAGE_SIZE+1))
+        if err != nil {
+            log.Printf("[BUILDER] file read: %+v", err)
+            ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.Response[interface{}]{
+                Message: err.Error(),
+                Data: nil,
+                Status: http.StatusInternalServerError,
+            })
+            return
+        }
+
+        if len(image) > MAX_IMAGE_SIZE {
+            ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.Response[interface{}]{
+                Message: "image is too large",
+                Data: nil,
+                Status: http.StatusRequestEntityTooLarge,
+            })
+            return
+        }
+
+        jobs.SubmitResponse(ctx, OCR_JOB, models.ImageJobRequest{Image: image})
+    }
+}
@@ -0,0 +1,345 @@
+package builder
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"logit/models"
+	"logit/units"
+)
+
+const (
+    // how many foods are ranked and returned by MatchFoods
+    MAX_FOOD_CANDIDATES = 5000
+    MAX_FOOD_MATCHES = 5
+    // below this a food shares too little with the ingredient to be it
+    MIN_FOOD_SCORE = 0.3
+)
+
+// recipe names for what the USDA calls something else, the USDA's head noun
+// first. A single word is only replaced when it's the whole name, so
+// "peanut butter" isn't "butter salted"
+var foodSynonyms = map[string]string{
+    "all-purpose flour":    "wheat flour white all-purpose",
+    "bread flour":          "wheat flour white bread",
+    "whole wheat flour":    "wheat flour whole-grain",
+    "powdered sugar":       "sugar powdered",
+    "confectioners sugar":  "sugar powdered",
+    "icing sugar":          "sugar powdered",
+    "brown sugar":          "sugar brown",
+    "granulated sugar":     "sugar granulated",
+    "ground beef":          "beef ground",
+    "ground turkey":        "turkey ground",
+    "ground pork":          "pork ground",
+    "chicken breast":       "chicken broiler breast meat",
+    "chicken thigh":        "chicken broiler thigh meat",
+    "heavy cream":          "cream fluid heavy whipping",
+    "whipping cream":       "cream fluid heavy whipping",
+    "sour cream":           "cream sour",
+    "cream cheese":         "cheese cream",
+    "parmesan":             "cheese parmesan",
+    "cheddar":              "cheese cheddar",
+    "mozzarella":           "cheese mozzarella",
+    "feta":                 "cheese feta",
+    "scallion":             "onion spring",
+    "green onion":          "onion spring",
+    "cilantro":             "coriander leaves",
+    "chickpea":             "chickpea garbanzo",
+    "garbanzo bean":        "chickpea garbanzo",
+    "bell pepper":          "pepper sweet",
+    "zucchini":             "squash summer zucchini",
+    "courgette":            "squash summer zucchini",
+    "aubergine":            "eggplant",
+    "baking soda":          "leavening agent baking soda",
+    "baking powder":        "leavening agent baking powder",
+    "olive oil":            "oil olive",
+    "vegetable oil":        "oil vegetable",
+    "canola oil":           "oil canola",
+    "coconut oil":          "oil coconut",
+    "kosher salt":          "salt table",
+    "sea salt":             "salt table",
+    "salt":                 "salt table",
+    "black pepper":         "spice pepper black",
+    "pepper":               "spice pepper black",
+    "cumin":                "spice cumin",
+    "cinnamon":             "spice cinnamon",
+    "paprika":              "spice paprika",
+    "oregano":              "spice oregano",
+    "egg":                  "egg whole",
+    "butter":               "butter salted",
+}
+
+// words of an ingredient that don't say what food it is
+var foodStopWords = map[string]bool{
+    "a": true, "an": true, "and": true, "or": true, "of": true, "the": true, "with": true,
+    "for": true, "to": true, "in": true, "fresh": true, "freshly": true, "large": true,
+    "medium": true, "small": true, "ripe": true, "chopped": true, "diced": true,
+    "minced": true, "sliced": true, "grated": true, "shredded": true, "finely": true,
+    "roughly": true, "thinly": true, "softened": true, "melted": true, "cold": true,
+    "warm": true, "room": true, "temperature": true, "packed": true, "divided": true,
+    "good": true, "quality": true, "extra": true, "organic": true,
+}
+
+type FoodMatch struct {
+    Food    Foods
+    Score   float64
+}
+
+// the words of an ingredient that name the food, and the one it's named
+// after, "flour" of "all-purpose flour"
+type foodQuery struct {
+    tokens  []string
+    head    string
+}
+
+func MatchFoods(name string, limit int) []FoodMatch {
+    // REQUIRES:    name of an ingredient, limit > 0
+    // MODIFIES:    none
+    // EFFECTS:     Returns up to [limit] foods [name] could be, the best first,
+    //              ranked by ScoreFood. Foods scoring under MIN_FOOD_SCORE
+    //              aren't returned
+
+    query := parseFoodQuery(name)
+    if query.head == "" {
+        return nil
+    }
+
+    // the head noun has to be in the description for a food to score, so
+    // only those are ranked. If there are more than can be ranked, the
+    // generic foods with short descriptions are kept rather than whichever
+    // the database returns first
+    var candidates []Foods
+    err := Db.Select("fdc_id", "description").
+        Where("description LIKE ?", "%"+likeStem(query.head)+"%").
+        Order("LENGTH(description), fdc_id").
+        Limit(MAX_FOOD_CANDIDATES).
+        Find(&candidates).Error
+    if err != nil {
+        log.Printf("[BUILDER] food search error: %+v", err)
+        return nil
+    }
+
+    var ranked []FoodMatch
+    for _, candidate := range candidates {
+        if score := scoreQuery(query, candidate.Description); score >= MIN_FOOD_SCORE {
+            ranked = append(ranked, FoodMatch{Food: candidate, Score: score})
+        }
+    }
+
+    // shorter descriptions are the more generic foods
+    sort.SliceStable(ranked, func(i, j int) bool {
+        if ranked[i].Score != ranked[j].Score {
+            return ranked[i].Score > ranked[j].Score
+        }
+        return len(ranked[i].Food.Description) < len(ranked[j].Food.Description)
+    })
+    if len(ranked) > limit {
+        ranked = ranked[:limit]
+    }
+
+    if len(ranked) == 0 {
+        return ranked
+    }
+
+    // the candidates only have the description, the nutrients are loaded
+    // for the ones that made it
+    ids := make([]int, len(ranked))
+    for i, match := range ranked {
+        ids[i] = match.Food.FdcId
+    }
+
+    var foods []Foods
+    if err := Db.Where("fdc_id IN ?", ids).Find(&foods).Error; err != nil {
+        log.Printf("[BUILDER] food error: %+v", err)
+        return ranked
+    }
+
+    byId := make(map[int]Foods, len(foods))
+    for _, food := range foods {
+        byId[food.FdcId] = food
+    }
+    for i := range ranked {
+        if food, exists := byId[ranked[i].Food.FdcId]; exists {
+            ranked[i].Food = food
+        }
+    }
+
+    return ranked
+}
+
+func ScoreFood(name, description string) float64 {
+    // REQUIRES:    name of an ingredient, description of a USDA food
+    // MODIFIES:    none
+    // EFFECTS:     Returns how likely the food is the ingredient, from 0 to 1.
+    //              The words are compared whole and singular, so "egg"
+    //              doesn't match "Eggplant". The head noun counts double and
+    //              has to be there, and words in the first part of the
+    //              description, "Butter" of "Butter, salted", count more.
+    //              Words of the description the name doesn't have cost a
+    //              little, more in the first part, so "Peanut butter" ranks
+    //              below "Butter". Counts after the food are dropped, "garlic
+    //              cloves" is garlic
+
+    return scoreQuery(parseFoodQuery(name), description)
+}
+
+func scoreQuery(query foodQuery, description string) float64 {
+    if query.head == "" {
+        return 0
+    }
+
+    // USDA descriptions go from the food to the details, "Egg, whole, raw"
+    parts := strings.SplitN(description, ",", 2)
+    first := tokenSet(parts[0])
+    rest := map[string]bool{}
+    if len(parts) > 1 {
+        rest = tokenSet(parts[1])
+    }
+
+    if !first[query.head] && !rest[query.head] {
+        return 0
+    }
+
+    queried := map[string]bool{}
+    var weight, matched float64
+    for _, token := range query.tokens {
+        queried[token] = true
+
+        w := 1.0
+        if token == query.head {
+            w = 2
+        }
+        weight += w
+
+        if first[token] {
+            matched += w
+        } else if rest[token] {
+            matched += 0.75 * w
+        }
+    }
+
+    var extra float64
+    for token := range first {
+        if !queried[token] {
+            extra += 0.5
+        }
+    }
+    for token := range rest {
+        // raw is what a recipe starts with, it isn't a different food
+        if !queried[token] && token != "raw" {
+            extra += 0.05
+        }
+    }
+
+    return (matched / weight) / (1 + extra)
+}
+
+func parseFoodQuery(name string) foodQuery {
+    tokens := trimCounts(tokenize(name))
+    normalized := " " + strings.Join(tokens, " ") + " "
+
+    // the longest phrase is the most specific, "all-purpose flour" over
+    // "flour"
+    var phrase string
+    for key := range foodSynonyms {
+        words := strings.Join(tokenize(key), " ")
+        matches := normalized == " "+words+" " ||
+            (strings.Contains(words, " ") && strings.Contains(normalized, " "+words+" "))
+        if matches && (len(key) > len(phrase) || (len(key) == len(phrase) && key < phrase)) {
+            phrase = key
+        }
+    }
+
+    if phrase == "" {
+        // the food is the last word, "chicken" of "roasted chicken"
+        var head string
+        if len(tokens) > 0 {
+            head = tokens[len(tokens)-1]
+        }
+        return foodQuery{tokens: tokens, head: head}
+    }
+
+    synonym := tokenize(foodSynonyms[phrase])
+    replaced := strings.Replace(normalized, " "+strings.Join(tokenize(phrase), " ")+" ", " "+strings.Join(synonym, " ")+" ", 1)
+
+    return foodQuery{tokens: strings.Fields(replaced), head: synonym[0]}
+}
+
+func trimCounts(tokens []string) []string {
+    // "garlic cloves" is garlic and "bread slices" is bread, what a clove
+    // weighs comes from the food's portions. On its own "cloves" is the
+    // food
+    for len(tokens) > 1 {
+        unit, ok := units.Lookup(tokens[len(tokens)-1])
+        if !ok || unit.Kind != units.COUNT {
+            break
+        }
+        tokens = tokens[:len(tokens)-1]
+    }
+
+    return tokens
+}
+
+func likeStem(token string) string {
+    // "strawberry" has to find "Strawberries"
+    return strings.TrimSuffix(token, "y")
+}
+
+func tokenize(text string) []string {
+    words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !isWordChar(r)
+    })
+
+    var tokens []string
+    for _, word := range words {
+        word = strings.Trim(word, "-")
+        if word != "" && !foodStopWords[word] {
+            tokens = append(tokens, singular(word))
+        }
+    }
+
+    return tokens
+}
+
+func tokenSet(text string) map[string]bool {
+    set := map[string]bool{}
+    for _, token := range tokenize(text) {
+        set[token] = true
+    }
+
+    return set
+}
+
+func isWordChar(r rune) bool {
+    // "all-purpose" is one word
+    return unicode.IsLetter(r) || r == '-'
+}
+
+func singular(word string) string {
+    // only has to turn both sides into the same word, "tomatoes" and
+    // "tomato" into "tomato", not to be right English
+    switch {
+    case len(word) <= 3:
+        return word
+    case strings.HasSuffix(word, "ies"):
+        return strings.TrimSuffix(word, "ies") + "y"
+    case strings.HasSuffix(word, "oes"), strings.HasSuffix(word, "ches"),
+        strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "sses"),
+        strings.HasSuffix(word, "xes"):
+        return strings.TrimSuffix(word, "es")
+    case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+        return strings.TrimSuffix(word, "s")
+    }
+
+    return word
+}
+
+func toCandidate(match FoodMatch) models.FoodCandidate {
+    return models.FoodCandidate{
+        FdcId:          match.Food.FdcId,
+        Description:    match.Food.Description,
+        Score:          match.Score,
+    }
+}
@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"testing"
+)
+
+func TestScoreFoodRanking(t *testing.T) {
+    // the descriptions are USDA foods, in the order they should rank for the
+    // ingredient. The first has to be a match, the last none if [last] is
+    // set
+    tests := []struct {
+        name            string
+        descriptions    []string
+        last            bool
+    }{
+        {"garlic cloves", []string{"Garlic, raw", "Spices, cloves, ground"}, true},
+        {"3 garlic cloves", []string{"Garlic, raw", "Spices, cloves, ground"}, true},
+        {"cloves", []string{"Spices, cloves, ground", "Garlic, raw"}, true},
+        {"bread slices", []string{"Bread, white, commercially prepared", "Bread crumbs, dry, grated, plain"}, false},
+        {"butter", []string{"Butter, salted", "Butter, without salt", "Peanut butter, smooth style, without salt"}, false},
+        {"peanut butter", []string{"Peanut butter, smooth style, without salt", "Butter, salted"}, false},
+        {"egg", []string{"Egg, whole, raw, fresh", "Egg, white, raw, fresh", "Eggplant, raw"}, true},
+        {"eggs", []string{"Egg, whole, raw, fresh", "Eggplant, raw"}, true},
+        {"eggplant", []string{"Eggplant, raw", "Egg, whole, raw, fresh"}, true},
+        {"all-purpose flour", []string{"Wheat flour, white, all-purpose, enriched, bleached", "Rice flour, white"}, false},
+        {"chopped fresh cilantro", []string{"Coriander (cilantro) leaves, raw", "Spices, coriander seed"}, false},
+        {"cherry tomatoes", []string{"Tomatoes, red, ripe, raw, year round average", "Cherries, sweet, raw"}, true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            scores := make([]float64, len(test.descriptions))
+            for i, description := range test.descriptions {
+                scores[i] = ScoreFood(test.name, description)
+            }
+
+            if scores[0] < MIN_FOOD_SCORE {
+                t.Errorf("%q scores %.3f, under MIN_FOOD_SCORE", test.descriptions[0], scores[0])
+            }
+            for i := 1; i < len(scores); i++ {
+                if scores[i] >= scores[i-1] {
+                    t.Errorf("%q scores %.3f, not below %q at %.3f",
+                        test.descriptions[i], scores[i], test.descriptions[i-1], scores[i-1])
+                }
+            }
+            if last := scores[len(scores)-1]; test.last && last >= MIN_FOOD_SCORE {
+                t.Errorf("%q scores %.3f, should be no match", test.descriptions[len(scores)-1], last)
+            }
+        })
+    }
+}
+
+func TestParseFoodQueryHead(t *testing.T) {
+    tests := []struct {
+        name    string
+        head    string
+    }{
+        {"garlic cloves", "garlic"},
+        {"garlic clove", "garlic"},
+        {"cloves", "clove"},
+        {"bread slices", "bread"},
+        {"celery stalks", "celery"},
+        // trimmed to "cinnamon", which has a synonym
+        {"cinnamon sticks", "spice"},
+        {"fresh thyme sprigs", "thyme"},
+        {"roasted chicken", "chicken"},
+        {"all-purpose flour", "wheat"},
+        {"peanut butter", "butter"},
+        {"large eggs", "egg"},
+    }
+
+    for _, test := range tests {
+        if head := parseFoodQuery(test.name).head; head != test.head {
+            t.Errorf("parseFoodQuery(%q).head = %q, want %q", test.name, head, test.head)
+        }
+    }
+}
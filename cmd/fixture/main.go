@@ -0,0 +1,122 @@
+// Saves a recipe page as a parser test fixture.
+//
+//	go run ./cmd/fixture -name some_site -log <id>
+//	go run ./cmd/fixture -name some_site -url https://example.com/recipe
+//
+// -log takes the id the parser logged for a page it couldn't get a recipe
+// from (<id>.html in SCRAPE_FAILURE_LOG_DIR, which turns the logging on), or
+// an ld+json dump from ./logs/<id>.json which is wrapped in a minimal page. Afterwards, check the page in and create its
+// golden file with:
+//
+//	go test ./parser -run TestFixtures/<name> -update
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"logit/parser"
+)
+
+const FIXTURE_DIR = "parser/testdata/fixtures"
+
+var fixtureName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func defaultLogDir() string {
+	if dir := os.Getenv("SCRAPE_FAILURE_LOG_DIR"); dir != "" {
+		return dir
+	}
+	return "./logs"
+}
+
+func main() {
+	name := flag.String("name", "", "fixture name, lowercase letters, digits and underscores")
+	logID := flag.String("log", "", "id of a logged page in -logs")
+	logDir := flag.String("logs", defaultLogDir(), "directory the parser logs failed pages to")
+	link := flag.String("url", "", "recipe page to download")
+	force := flag.Bool("force", false, "overwrite an existing fixture")
+	flag.Parse()
+
+	if !fixtureName.MatchString(*name) {
+		log.Fatalf("-name must match %s", fixtureName)
+	}
+	if (*logID == "") == (*link == "") {
+		log.Fatal("pass exactly one of -log or -url")
+	}
+
+	var page []byte
+	var err error
+	if *logID != "" {
+		page, err = readLog(*logDir, *logID)
+	} else {
+		page, err = download(*link)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	path := filepath.Join(FIXTURE_DIR, *name+".html")
+	if _, err := os.Stat(path); err == nil && !*force {
+		log.Fatalf("%s already exists, pass -force to overwrite it", path)
+	}
+
+	if err := os.WriteFile(path, page, 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("saved %s\n", path)
+	fmt.Printf("create its golden file with: go test ./parser -run TestFixtures/%s -update\n", *name)
+}
+
+func readLog(dir string, id string) ([]byte, error) {
+	// pages saved by the parser can be used as they are
+	if page, err := os.ReadFile(filepath.Join(dir, id+".html")); err == nil {
+		return page, nil
+	}
+
+	ldJSON, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("no logged page or ld+json for %s in %s", id, dir)
+	}
+
+	page := fmt.Sprintf(
+		"<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<script type=\"application/ld+json\">%s</script>\n</head>\n<body>\n</body>\n</html>\n",
+		ldJSON,
+	)
+
+	return []byte(page), nil
+}
+
+func download(link string) ([]byte, error) {
+	if _, err := parser.Policy.ValidateURL(link); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: parser.Policy.Timeout,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/115.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", link, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, int64(parser.Policy.MaxBodySize)))
+}
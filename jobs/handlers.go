@@ -0,0 +1,133 @@
+package jobs
+
+import (
+    "errors"
+    "io"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "logit/models"
+)
+
+// how often the event stream checks the job for changes
+const EVENT_INTERVAL = 500 * time.Millisecond
+
+func SubmitResponse(ctx *gin.Context, kind string, payload interface{}) {
+    // REQUIRES:    ctx, kind, payload
+    // MODIFIES:    ctx
+    // EFFECTS:     Submits a job and responds with it, or with why it
+    //              couldn't be queued. Used by the async variants of the
+    //              scrape, OCR and build endpoints
+
+    job, err := Submit(kind, payload)
+    if err != nil {
+        log.Printf("[JOBS] submit error: %+v\n", err)
+
+        status := http.StatusInternalServerError
+        if errors.Is(err, ErrQueueFull) {
+            status = http.StatusServiceUnavailable
+        }
+
+        ctx.AbortWithStatusJSON(status, models.Response[interface{}]{
+            Message: err.Error(),
+            Data: nil,
+            Status: status,
+        })
+        return
+    }
+
+    job.Payload = nil
+    ctx.JSON(http.StatusAccepted, models.Response[models.Job]{
+        Message: "job queued",
+        Data: job,
+        Status: http.StatusAccepted,
+    })
+}
+
+func getJob(ctx *gin.Context) *models.Job {
+    job, err := Get(ctx.Param("id"))
+    if err != nil {
+        log.Printf("[JOBS] store error: %+v\n", err)
+        ctx.AbortWithStatusJSON(http.StatusInternalServerError, models.Response[interface{}]{
+            Message: "couldn't load the job",
+            Data: nil,
+            Status: http.StatusInternalServerError,
+        })
+        return nil
+    }
+
+    if job == nil {
+        ctx.AbortWithStatusJSON(http.StatusNotFound, models.Response[interface{}]{
+            Message: "job doesn't exist or has expired",
+            Data: nil,
+            Status: http.StatusNotFound,
+        })
+        return nil
+    }
+
+    job.Payload = nil
+    return job
+}
+
+func StatusHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        job := getJob(ctx)
+        if job == nil {
+            return
+        }
+
+        ctx.JSON(http.StatusOK, models.Response[models.Job]{
+            Message: "job " + job.State,
+            Data: *job,
+            Status: http.StatusOK,
+        })
+    }
+}
+
+func EventsHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        // sends the job as a server-sent event named after its state every
+        // time it changes, and closes the stream once it has finished
+        job := getJob(ctx)
+        if job == nil {
+            return
+        }
+
+        ctx.Header("Cache-Control", "no-cache")
+        ctx.Header("X-Accel-Buffering", "no")
+        ctx.SSEvent(job.State, job)
+        if IsFinished(job) {
+            return
+        }
+
+        ticker := time.NewTicker(EVENT_INTERVAL)
+        defer ticker.Stop()
+
+        lastUpdate := job.UpdatedAt
+        ctx.Stream(func(w io.Writer) bool {
+            select {
+            case <-ctx.Request.Context().Done():
+                return false
+            case <-ticker.C:
+            }
+
+            job, err := Get(ctx.Param("id"))
+            if err != nil || job == nil {
+                ctx.SSEvent("error", "job doesn't exist or has expired")
+                return false
+            }
+
+            if job.UpdatedAt.Equal(lastUpdate) {
+                return true
+            }
+
+            lastUpdate = job.UpdatedAt
+            job.Payload = nil
+            ctx.SSEvent(job.State, job)
+            return !IsFinished(job)
+        })
+    }
+}
@@ -0,0 +1,191 @@
+package jobs
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+
+    "logit/models"
+)
+
+// job states
+const (
+    QUEUED  = "queued"
+    RUNNING = "running"
+    DONE    = "done"
+    FAILED  = "failed"
+)
+
+// how long a job and its result can be polled after it was last updated
+const JOB_EXPIRY = 24 * time.Hour
+
+// Runner does the work for one kind of job. It decodes [payload], reports
+// what it's doing through [progress] and returns the status and the
+// models.Response the synchronous endpoint would have responded with
+type Runner func(payload []byte, progress func(percent int, step string)) (int, interface{})
+
+var (
+    runners     = map[string]Runner{}
+    runnersLock sync.RWMutex
+)
+
+func Register(kind string, runner Runner) {
+    // REQUIRES:    kind, runner
+    // MODIFIES:    runners
+    // EFFECTS:     Makes the workers run [runner] for jobs of [kind]. Every
+    //              process running workers has to register the kinds it picks
+    //              up from the queue
+
+    runnersLock.Lock()
+    defer runnersLock.Unlock()
+
+    runners[kind] = runner
+}
+
+func Submit(kind string, payload interface{}) (models.Job, error) {
+    // REQUIRES:    kind, payload that can be marshaled to JSON
+    // MODIFIES:    Store, Queue
+    // EFFECTS:     Stores a new job for [payload] and queues it for the
+    //              workers. Returns the job so the client gets its id
+
+    payloadJSON, err := json.Marshal(payload)
+    if err != nil {
+        return models.Job{}, err
+    }
+
+    now := time.Now()
+    job := models.Job{
+        Id:         uuid.New().String(),
+        Kind:       kind,
+        State:      QUEUED,
+        Payload:    payloadJSON,
+        CreatedAt:  now,
+        UpdatedAt:  now,
+    }
+
+    if err := Store.Set(job, JOB_EXPIRY); err != nil {
+        return models.Job{}, err
+    }
+
+    if err := Queue.Push(job.Id); err != nil {
+        return models.Job{}, err
+    }
+
+    return job, nil
+}
+
+func Get(id string) (*models.Job, error) {
+    return Store.Get(id)
+}
+
+func IsFinished(job *models.Job) bool {
+    return job.State == DONE || job.State == FAILED
+}
+
+func StartWorkers(n int) {
+    // REQUIRES:    n > 0, runners registered
+    // MODIFIES:    none
+    // EFFECTS:     Starts [n] goroutines that run queued jobs until the
+    //              process exits
+
+    for i := 0; i < n; i++ {
+        go work()
+    }
+}
+
+func work() {
+    for {
+        id, err := Queue.Pop()
+        if err != nil {
+            log.Printf("[JOBS] queue error: %+v\n", err)
+            time.Sleep(time.Second)
+            continue
+        }
+
+        job, err := Store.Get(id)
+        if err != nil || job == nil {
+            log.Printf("[JOBS] job %s is gone: %+v\n", id, err)
+            continue
+        }
+
+        run(job)
+    }
+}
+
+func run(job *models.Job) {
+    // REQUIRES:    job
+    // MODIFIES:    job, Store
+    // EFFECTS:     Runs [job] with the runner for its kind and stores its
+    //              progress along the way and its result at the end. A
+    //              panicking runner fails the job instead of the worker
+
+    runnersLock.RLock()
+    runner, exists := runners[job.Kind]
+    runnersLock.RUnlock()
+
+    if !exists {
+        finish(job, http.StatusInternalServerError, models.Response[interface{}]{
+            Message: fmt.Sprintf("no runner for %s jobs", job.Kind),
+            Data: nil,
+            Status: http.StatusInternalServerError,
+        })
+        return
+    }
+
+    update(job, RUNNING, 0, "started")
+
+    defer func() {
+        if r := recover(); r != nil {
+            log.Printf("[JOBS] job %s panicked: %+v\n", job.Id, r)
+            finish(job, http.StatusInternalServerError, models.Response[interface{}]{
+                Message: "something went wrong while running the job",
+                Data: nil,
+                Status: http.StatusInternalServerError,
+            })
+        }
+    }()
+
+    status, res := runner(job.Payload, func(percent int, step string) {
+        update(job, RUNNING, percent, step)
+    })
+    finish(job, status, res)
+}
+
+func update(job *models.Job, state string, percent int, step string) {
+    job.State = state
+    job.Progress = percent
+    job.Step = step
+    job.UpdatedAt = time.Now()
+
+    if err := Store.Set(*job, JOB_EXPIRY); err != nil {
+        log.Printf("[JOBS] store error: %+v\n", err)
+    }
+}
+
+func finish(job *models.Job, status int, res interface{}) {
+    resJSON, err := json.Marshal(res)
+    if err != nil {
+        log.Printf("[JOBS] marshal error: %+v\n", err)
+        status = http.StatusInternalServerError
+        resJSON, _ = json.Marshal(models.Response[interface{}]{
+            Message: "couldn't save the job result",
+            Data: nil,
+            Status: http.StatusInternalServerError,
+        })
+    }
+
+    job.Result = resJSON
+    // the payload isn't needed anymore and can be large, e.g. for OCR
+    job.Payload = nil
+
+    if status >= http.StatusBadRequest {
+        update(job, FAILED, 100, "failed")
+    } else {
+        update(job, DONE, 100, "done")
+    }
+}
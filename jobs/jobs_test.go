@@ -0,0 +1,212 @@
+package jobs
+
+import (
+    "bufio"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "logit/models"
+)
+
+// gives the test a store and queue of its own
+func withMemoryJobs(t *testing.T) {
+    store, queue := Store, Queue
+    Store, Queue = NewMemoryStore(), NewMemoryQueue()
+    t.Cleanup(func() { Store, Queue = store, queue })
+}
+
+// a runner that reports halfway and waits for [proceed] before it answers
+// with [status] and its payload
+func blockingRunner(status int, halfway chan<- struct{}, proceed <-chan struct{}) Runner {
+    return func(payload []byte, progress func(percent int, step string)) (int, interface{}) {
+        progress(50, "halfway")
+        halfway <- struct{}{}
+        <-proceed
+        return status, models.Response[json.RawMessage]{Data: payload, Status: status}
+    }
+}
+
+// runs the next queued job in the background, like a worker
+func runNext(t *testing.T) <-chan struct{} {
+    id, err := Queue.Pop()
+    if err != nil {
+        t.Fatal(err)
+    }
+    job, err := Store.Get(id)
+    if err != nil || job == nil {
+        t.Fatalf("queued job %s isn't stored: %+v", id, err)
+    }
+
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        run(job)
+    }()
+
+    return done
+}
+
+func mustGet(t *testing.T, id string) *models.Job {
+    job, err := Get(id)
+    if err != nil || job == nil {
+        t.Fatalf("job %s = %+v, %+v", id, job, err)
+    }
+    return job
+}
+
+func TestJobLifecycle(t *testing.T) {
+    withMemoryJobs(t)
+
+    tests := []struct {
+        kind    string
+        status  int
+        state   string
+    }{
+        {"test-done", http.StatusOK, DONE},
+        {"test-failed", http.StatusUnprocessableEntity, FAILED},
+    }
+
+    for _, test := range tests {
+        halfway, proceed := make(chan struct{}), make(chan struct{})
+        Register(test.kind, blockingRunner(test.status, halfway, proceed))
+
+        submitted, err := Submit(test.kind, map[string]string{"link": "https://example.com"})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if job := mustGet(t, submitted.Id); job.State != QUEUED || job.Progress != 0 {
+            t.Errorf("%s submitted as %s %d%%, want %s", test.kind, job.State, job.Progress, QUEUED)
+        }
+
+        done := runNext(t)
+        <-halfway
+        if job := mustGet(t, submitted.Id); job.State != RUNNING || job.Progress != 50 || job.Step != "halfway" {
+            t.Errorf("%s running as %s %d%% %q, want %s 50%% halfway", test.kind, job.State, job.Progress, job.Step, RUNNING)
+        }
+
+        close(proceed)
+        <-done
+        job := mustGet(t, submitted.Id)
+        if job.State != test.state || job.Progress != 100 || !IsFinished(job) {
+            t.Errorf("%s finished as %s %d%%, want %s 100%%", test.kind, job.State, job.Progress, test.state)
+        }
+        if job.Payload != nil {
+            t.Errorf("%s kept its payload", test.kind)
+        }
+
+        var result models.Response[map[string]string]
+        if err := json.Unmarshal(job.Result, &result); err != nil || result.Status != test.status || result.Data["link"] != "https://example.com" {
+            t.Errorf("%s result = %s, %+v", test.kind, job.Result, err)
+        }
+        if job.UpdatedAt.Before(job.CreatedAt) {
+            t.Errorf("%s was updated before it was created", test.kind)
+        }
+    }
+}
+
+func TestJobFailures(t *testing.T) {
+    withMemoryJobs(t)
+
+    Register("test-panic", func([]byte, func(int, string)) (int, interface{}) {
+        panic("runner broke")
+    })
+
+    for _, kind := range []string{"test-panic", "test-unregistered"} {
+        submitted, err := Submit(kind, nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        <-runNext(t)
+
+        job := mustGet(t, submitted.Id)
+        var result models.Response[interface{}]
+        if job.State != FAILED || json.Unmarshal(job.Result, &result) != nil || result.Status != http.StatusInternalServerError {
+            t.Errorf("%s = %s %s, want it %s with a %d", kind, job.State, job.Result, FAILED, http.StatusInternalServerError)
+        }
+    }
+}
+
+func TestEventsHandler(t *testing.T) {
+    withMemoryJobs(t)
+
+    halfway, proceed := make(chan struct{}), make(chan struct{})
+    Register("test-events", blockingRunner(http.StatusOK, halfway, proceed))
+
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    router.GET("/jobs/:id", StatusHandler())
+    router.GET("/jobs/:id/events", EventsHandler())
+    server := httptest.NewServer(router)
+    defer server.Close()
+
+    for _, path := range []string{"/jobs/missing", "/jobs/missing/events"} {
+        response, err := http.Get(server.URL + path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        response.Body.Close()
+        if response.StatusCode != http.StatusNotFound {
+            t.Errorf("%s = %d, want %d", path, response.StatusCode, http.StatusNotFound)
+        }
+    }
+
+    submitted, err := Submit("test-events", map[string]string{"link": "https://example.com"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    done := runNext(t)
+    <-halfway
+
+    response, err := http.Get(server.URL + "/jobs/" + submitted.Id + "/events")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer response.Body.Close()
+
+    // the job as it is, then every change until it's finished
+    var events []string
+    lines := bufio.NewScanner(response.Body)
+    for lines.Scan() {
+        event := strings.TrimPrefix(lines.Text(), "event:")
+        if event == lines.Text() {
+            continue
+        }
+
+        events = append(events, event)
+        // the job finishes once the stream is open, the change is sent on
+        // the next check
+        if event == RUNNING && len(events) == 1 {
+            close(proceed)
+        }
+    }
+    <-done
+
+    if strings.Join(events, ",") != RUNNING+","+DONE {
+        t.Errorf("events = %v, want %s then %s", events, RUNNING, DONE)
+    }
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+    store := NewMemoryStore()
+    store.Set(models.Job{Id: "expired"}, time.Nanosecond)
+    store.Set(models.Job{Id: "kept"}, JOB_EXPIRY)
+    time.Sleep(time.Millisecond)
+
+    // nobody polls the expired job, the next Set after the interval drops it
+    store.lastSweep = time.Now().Add(-MEMORY_STORE_SWEEP)
+    store.Set(models.Job{Id: "new"}, JOB_EXPIRY)
+
+    store.lock.RLock()
+    _, expired := store.jobs["expired"]
+    _, kept := store.jobs["kept"]
+    store.lock.RUnlock()
+    if expired || !kept {
+        t.Errorf("after the sweep expired is held %v, kept %v, want only kept", expired, kept)
+    }
+}
@@ -0,0 +1,125 @@
+package jobs
+
+import (
+    "errors"
+    "sync"
+    "time"
+
+    "logit/models"
+)
+
+const (
+    // most jobs waiting in the in-memory queue
+    MEMORY_QUEUE_SIZE = 1024
+    // how often Set drops the expired jobs nobody polled for again
+    MEMORY_STORE_SWEEP = 10 * time.Minute
+)
+
+// JobStore keeps jobs by id. Get returns nil without an error when the job
+// doesn't exist. redis.JobStore implements it on top of the shared client
+type JobStore interface {
+    Get(id string) (*models.Job, error)
+    Set(job models.Job, ttl time.Duration) error
+}
+
+// JobQueue hands job ids to the workers. Pop blocks until there is one.
+// redis.JobQueue implements it so jobs can be run by other processes
+type JobQueue interface {
+    Push(id string) error
+    Pop() (string, error)
+}
+
+// Store and Queue default to this process and can be swapped for
+// redis.JobStore{} and redis.JobQueue{} once redis is configured
+var (
+    Store JobStore = NewMemoryStore()
+    Queue JobQueue = NewMemoryQueue()
+)
+
+var ErrQueueFull = errors.New("too many jobs are waiting, try again later")
+
+type memoryJob struct {
+    job     models.Job
+    expires time.Time
+}
+
+// MemoryStore is a JobStore local to this process
+type MemoryStore struct {
+    jobs        map[string]memoryJob
+    lastSweep   time.Time
+    lock        sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{
+        jobs:       map[string]memoryJob{},
+        lastSweep:  time.Now(),
+    }
+}
+
+func (m *MemoryStore) Get(id string) (*models.Job, error) {
+    m.lock.RLock()
+    stored, exists := m.jobs[id]
+    m.lock.RUnlock()
+
+    if !exists {
+        return nil, nil
+    }
+
+    if time.Now().After(stored.expires) {
+        m.lock.Lock()
+        delete(m.jobs, id)
+        m.lock.Unlock()
+        return nil, nil
+    }
+
+    job := stored.job
+    return &job, nil
+}
+
+func (m *MemoryStore) Set(job models.Job, ttl time.Duration) error {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+
+    // jobs are set as they run, so expired ones are dropped without a
+    // goroutine of their own
+    if now := time.Now(); now.Sub(m.lastSweep) >= MEMORY_STORE_SWEEP {
+        for id, stored := range m.jobs {
+            if now.After(stored.expires) {
+                delete(m.jobs, id)
+            }
+        }
+        m.lastSweep = now
+    }
+
+    m.jobs[job.Id] = memoryJob{
+        job:        job,
+        expires:    time.Now().Add(ttl),
+    }
+
+    return nil
+}
+
+// MemoryQueue is a JobQueue local to this process
+type MemoryQueue struct {
+    ids chan string
+}
+
+func NewMemoryQueue() *MemoryQueue {
+    return &MemoryQueue{
+        ids: make(chan string, MEMORY_QUEUE_SIZE),
+    }
+}
+
+func (m *MemoryQueue) Push(id string) error {
+    select {
+    case m.ids <- id:
+        return nil
+    default:
+        return ErrQueueFull
+    }
+}
+
+func (m *MemoryQueue) Pop() (string, error) {
+    return <-m.ids, nil
+}
@@ -0,0 +1,254 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	// logit libs
+	"logit/builder"
+	"logit/models"
+	"logit/parser"
+)
+
+// SavedRecipe is a row of the recipe library. The normalized recipe is
+// stored as JSON, it's only ever read back as a whole
+type SavedRecipe struct {
+    Id          uint        `gorm:"primaryKey"`
+    UserId      string      `gorm:"size:64;index;not null"`
+    Name        string      `gorm:"size:255"`
+    Source      string      `gorm:"size:16"`
+    Link        string      `gorm:"size:2048"`
+    Recipe      string      `gorm:"type:mediumtext"`
+    // the RecipeVersion of Recipe. Re-scrapes that kept the user's edits
+    // are in the history after it
+    Version     int
+    Changed     bool
+    // set when the user changed more than the servings, a re-scrape then
+    // doesn't replace their recipe
+    Edited      bool
+    // models.RecipeDiff of the re-scrape that set Changed
+    Changes     string      `gorm:"type:text"`
+    CreatedAt   time.Time
+    UpdatedAt   time.Time
+}
+
+func Migrate() error {
+    // REQUIRES:    builder.ConfigureDB was called
+    // MODIFIES:    the database
+    // EFFECTS:     Creates or updates the library tables next to the USDA ones
+
+    return builder.Db.AutoMigrate(&SavedRecipe{}, &RecipeVersion{})
+}
+
+func (s SavedRecipe) toModel() (models.LibraryRecipe, error) {
+    var recipe models.Recipe
+    if err := json.Unmarshal([]byte(s.Recipe), &recipe); err != nil {
+        log.Printf("[LIBRARY] unmarshal error: %+v", err)
+        return models.LibraryRecipe{}, err
+    }
+
+    changes, err := unmarshalDiff(s.Changes)
+    if err != nil {
+        return models.LibraryRecipe{}, err
+    }
+
+    return models.LibraryRecipe{
+        Id:         s.Id,
+        Name:       s.Name,
+        Source:     s.Source,
+        Link:       s.Link,
+        Recipe:     recipe,
+        Version:    s.Version,
+        Changed:    s.Changed,
+        Changes:    changes,
+        CreatedAt:  s.CreatedAt,
+        UpdatedAt:  s.UpdatedAt,
+    }, nil
+}
+
+func SaveRecipe(userId, source, link string, recipe models.Recipe) (models.LibraryRecipe, error) {
+    // REQUIRES:    userId, source is one of the models.SOURCE_ constants
+    // MODIFIES:    the database
+    // EFFECTS:     Adds [recipe] to the user's library as its first version
+    //              and returns it
+
+    recipeJSON, err := json.Marshal(recipe)
+    if err != nil {
+        return models.LibraryRecipe{}, err
+    }
+
+    saved := SavedRecipe{
+        UserId:     userId,
+        Name:       recipe.Name,
+        Source:     source,
+        Link:       link,
+        Recipe:     string(recipeJSON),
+        Version:    1,
+    }
+    err = builder.Db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(&saved).Error; err != nil {
+            return err
+        }
+
+        return addVersion(tx, saved, models.VERSION_SAVED, nil)
+    })
+    if err != nil {
+        log.Printf("[LIBRARY] save error: %+v", err)
+        return models.LibraryRecipe{}, err
+    }
+
+    return saved.toModel()
+}
+
+func ListRecipes(userId string) ([]models.LibraryRecipe, error) {
+    // REQUIRES:    userId
+    // MODIFIES:    none
+    // EFFECTS:     Returns the user's recipes, the most recently changed first
+
+    var rows []SavedRecipe
+    if err := builder.Db.Where("user_id = ?", userId).Order("updated_at desc").Find(&rows).Error; err != nil {
+        log.Printf("[LIBRARY] list error: %+v", err)
+        return nil, err
+    }
+
+    recipes := make([]models.LibraryRecipe, 0, len(rows))
+    for _, row := range rows {
+        recipe, err := row.toModel()
+        if err != nil {
+            return nil, err
+        }
+        recipes = append(recipes, recipe)
+    }
+
+    return recipes, nil
+}
+
+func getRow(userId string, id uint) (*SavedRecipe, error) {
+    // other users' recipes look the same as missing ones
+    var row SavedRecipe
+    err := builder.Db.Where("id = ? AND user_id = ?", id, userId).First(&row).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, nil
+    } else if err != nil {
+        log.Printf("[LIBRARY] get error: %+v", err)
+        return nil, err
+    }
+
+    return &row, nil
+}
+
+func GetRecipe(userId string, id uint) (*models.LibraryRecipe, error) {
+    // REQUIRES:    userId
+    // MODIFIES:    none
+    // EFFECTS:     Returns the user's recipe with [id], or nil without an
+    //              error if they don't have one
+
+    row, err := getRow(userId, id)
+    if row == nil {
+        return nil, err
+    }
+
+    recipe, err := row.toModel()
+    if err != nil {
+        return nil, err
+    }
+
+    return &recipe, nil
+}
+
+func UpdateRecipe(userId string, id uint, name string, recipe models.Recipe, reason string, acknowledge bool) (*models.LibraryRecipe, error) {
+    // REQUIRES:    userId, reason is VERSION_EDITED or VERSION_RESCALED
+    // MODIFIES:    the database
+    // EFFECTS:     Replaces the user's recipe with [id] and returns it, or nil
+    //              without an error if they don't have one. A changed recipe
+    //              becomes a new version for [reason], only an edit keeps
+    //              re-scrapes from replacing it. [acknowledge] clears Changed
+
+    row, err := getRow(userId, id)
+    if row == nil {
+        return nil, err
+    }
+
+    current, err := row.toModel()
+    if err != nil {
+        return nil, err
+    }
+
+    recipeJSON, err := json.Marshal(recipe)
+    if err != nil {
+        return nil, err
+    }
+
+    // recipes edited before there was Edited only have the version's reason
+    edited, err := isEdited(*row)
+    if err != nil {
+        log.Printf("[LIBRARY] history error: %+v", err)
+        return nil, err
+    }
+
+    err = builder.Db.Transaction(func(tx *gorm.DB) error {
+        row.Name = name
+        if acknowledge {
+            row.Changed = false
+            row.Changes = ""
+        }
+
+        if string(recipeJSON) != row.Recipe {
+            version, err := nextVersion(tx, row.Id)
+            if err != nil {
+                return err
+            }
+
+            diff := parser.DiffRecipes(current.Recipe, recipe)
+            row.Recipe = string(recipeJSON)
+            row.Version = version
+            row.Edited = edited || reason == models.VERSION_EDITED
+            if err := addVersion(tx, *row, reason, &diff); err != nil {
+                return err
+            }
+        }
+
+        return tx.Save(row).Error
+    })
+    if err != nil {
+        log.Printf("[LIBRARY] update error: %+v", err)
+        return nil, err
+    }
+
+    updated, err := row.toModel()
+    if err != nil {
+        return nil, err
+    }
+
+    return &updated, nil
+}
+
+func DeleteRecipe(userId string, id uint) (bool, error) {
+    // REQUIRES:    userId
+    // MODIFIES:    the database
+    // EFFECTS:     Removes the user's recipe with [id] and its history,
+    //              returns false if they don't have one
+
+    row, err := getRow(userId, id)
+    if row == nil {
+        return false, err
+    }
+
+    err = builder.Db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Where("recipe_id = ?", row.Id).Delete(&RecipeVersion{}).Error; err != nil {
+            return err
+        }
+
+        return tx.Delete(row).Error
+    })
+    if err != nil {
+        log.Printf("[LIBRARY] delete error: %+v", err)
+        return false, err
+    }
+
+    return true, nil
+}
@@ -0,0 +1,445 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	// logit libs
+	"logit/builder"
+	"logit/fitbit"
+	"logit/models"
+	"logit/parser"
+	"logit/redis"
+)
+
+func abortWith(ctx *gin.Context, status int, message string) {
+    ctx.AbortWithStatusJSON(status, models.Response[interface{}]{
+        Message: message,
+        Data: nil,
+        Status: status,
+    })
+}
+
+func sessionOf(ctx *gin.Context) (*models.SessionData, bool) {
+    // REQUIRES:    ctx
+    // MODIFIES:    ctx
+    // EFFECTS:     Returns the logged in user's session, or responds with 401
+    //              and returns false if there is none
+
+    sessData, err := redis.GetSession(ctx.Request.Header.Get("Authorization"))
+    if err != nil || sessData == nil {
+        log.Print("[LIBRARY] error: not authorized to use the recipe library")
+        abortWith(ctx, http.StatusUnauthorized, "not authorized to use the recipe library")
+        return nil, false
+    }
+
+    return sessData, true
+}
+
+func recipeIdOf(ctx *gin.Context) (uint, bool) {
+    id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+    if err != nil {
+        abortWith(ctx, http.StatusBadRequest, "recipe id must be a number")
+        return 0, false
+    }
+
+    return uint(id), true
+}
+
+func abortOnScrapeError(ctx *gin.Context, err error) bool {
+    // partial recipes can still be saved
+    var scrapeErr *parser.ScrapeError
+    if !errors.As(err, &scrapeErr) || scrapeErr.Code == parser.PARTIAL_RECIPE {
+        return false
+    }
+
+    ctx.AbortWithStatusJSON(scrapeErr.Status, models.Response[interface{}]{
+        Message: scrapeErr.Message,
+        Data: nil,
+        Status: scrapeErr.Status,
+        Code: scrapeErr.Code,
+    })
+    return true
+}
+
+func abortOnPartialRecipe(ctx *gin.Context, err error) bool {
+    // a refresh that only got part of the recipe would replace the complete
+    // one the user saved with less
+    var scrapeErr *parser.ScrapeError
+    if !errors.As(err, &scrapeErr) || scrapeErr.Code != parser.PARTIAL_RECIPE {
+        return false
+    }
+
+    ctx.AbortWithStatusJSON(http.StatusBadGateway, models.Response[interface{}]{
+        Message: scrapeErr.Message + ", kept the saved recipe",
+        Data: nil,
+        Status: http.StatusBadGateway,
+        Code: scrapeErr.Code,
+    })
+    return true
+}
+
+func SaveRecipeHandler(uagents []string) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        var req models.SaveRecipeRequest
+        if err := ctx.ShouldBindJSON(&req); err != nil || (req.Recipe == nil && req.Link == "") {
+            abortWith(ctx, http.StatusBadRequest, "expected a recipe or a link to one")
+            return
+        }
+
+        if req.Source == "" {
+            req.Source = models.SOURCE_BUILD
+            if req.Link != "" {
+                req.Source = models.SOURCE_SCRAPE
+            }
+        }
+        switch req.Source {
+        case models.SOURCE_SCRAPE, models.SOURCE_BUILD, models.SOURCE_TEXT:
+        default:
+            abortWith(ctx, http.StatusBadRequest, "source must be scrape, build or text")
+            return
+        }
+
+        // a link alone is looked up like /calculate would, which is usually
+        // a cache hit since the app just showed the recipe
+        if req.Recipe == nil {
+            recipe, err := parser.FetchRecipe(req.Link, uagents)
+            if abortOnScrapeError(ctx, err) {
+                return
+            }
+            req.Recipe = &recipe
+        }
+
+        saved, err := SaveRecipe(sessData.AuthData.UserId, req.Source, req.Link, *req.Recipe)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't save the recipe")
+            return
+        }
+
+        ctx.JSON(http.StatusCreated, models.Response[models.LibraryRecipe]{
+            Message: "recipe saved",
+            Data: saved,
+            Status: http.StatusCreated,
+        })
+    }
+}
+
+func ListRecipesHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        recipes, err := ListRecipes(sessData.AuthData.UserId)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't load the recipe library")
+            return
+        }
+
+        ctx.JSON(http.StatusOK, models.Response[[]models.LibraryRecipe]{
+            Message: "recipes found",
+            Data: recipes,
+            Status: http.StatusOK,
+        })
+    }
+}
+
+func GetRecipeHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        id, ok := recipeIdOf(ctx)
+        if !ok {
+            return
+        }
+
+        saved, err := GetRecipe(sessData.AuthData.UserId, id)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't load the recipe")
+            return
+        } else if saved == nil {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        }
+
+        ctx.JSON(http.StatusOK, models.Response[models.LibraryRecipe]{
+            Message: "recipe found",
+            Data: *saved,
+            Status: http.StatusOK,
+        })
+    }
+}
+
+func UpdateRecipeHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        id, ok := recipeIdOf(ctx)
+        if !ok {
+            return
+        }
+
+        var req models.UpdateRecipeRequest
+        if err := ctx.ShouldBindJSON(&req); err != nil {
+            abortWith(ctx, http.StatusBadRequest, "doesn't follow expected input format")
+            return
+        }
+        if req.Servings != nil && *req.Servings <= 0 {
+            abortWith(ctx, http.StatusBadRequest, "servings must be a positive number")
+            return
+        }
+
+        saved, err := GetRecipe(sessData.AuthData.UserId, id)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't load the recipe")
+            return
+        } else if saved == nil {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        }
+
+        name := saved.Name
+        if req.Name != nil {
+            name = *req.Name
+        }
+
+        recipe := saved.Recipe
+        reason := models.VERSION_EDITED
+        var buildErrors []string
+        if req.Ingredients != nil {
+            // the site's nutrition no longer matches, so the builder adds up
+            // the new ingredients instead
+            if req.Servings != nil {
+                recipe.Servings = *req.Servings
+            }
+
+            built, err := builder.BuildNutrition(models.IngredientParseRequest{List: req.Ingredients}, nil)
+            if err != nil {
+                abortWith(ctx, http.StatusBadRequest, "couldn't parse ingredients")
+                return
+            }
+
+            recipe.Ingredients = req.Ingredients
+            parser.SetTotalNutrition(&recipe, built.Nutrition)
+            buildErrors = built.Errors
+        } else if req.Servings != nil {
+            // still the site's recipe, a refresh is rescaled the same way
+            parser.RescaleServings(&recipe, *req.Servings)
+            reason = models.VERSION_RESCALED
+        }
+
+        updated, err := UpdateRecipe(sessData.AuthData.UserId, id, name, recipe, reason, req.AcknowledgeChanges)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't update the recipe")
+            return
+        } else if updated == nil {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        }
+
+        ctx.JSON(http.StatusOK, models.Response[models.UpdateRecipeResponse]{
+            Message: "recipe updated",
+            Data: models.UpdateRecipeResponse{
+                Recipe: *updated,
+                Errors: buildErrors,
+            },
+            Status: http.StatusOK,
+        })
+    }
+}
+
+func RefreshRecipeHandler(uagents []string) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        // scrapes a saved recipe's link again and records what the site
+        // changed since
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        id, ok := recipeIdOf(ctx)
+        if !ok {
+            return
+        }
+
+        saved, err := GetRecipe(sessData.AuthData.UserId, id)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't load the recipe")
+            return
+        } else if saved == nil {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        } else if saved.Source != models.SOURCE_SCRAPE || saved.Link == "" {
+            abortWith(ctx, http.StatusBadRequest, "only recipes saved from a link can be refreshed")
+            return
+        }
+
+        scraped, err := parser.RefetchRecipe(saved.Link, uagents)
+        if abortOnScrapeError(ctx, err) || abortOnPartialRecipe(ctx, err) {
+            return
+        }
+
+        refreshed, diff, err := RefreshRecipe(sessData.AuthData.UserId, id, scraped)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't update the recipe")
+            return
+        } else if refreshed == nil {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        }
+
+        message := "recipe is up to date"
+        if diff != nil {
+            message = "the recipe changed on the site"
+        }
+
+        ctx.JSON(http.StatusOK, models.Response[models.LibraryRecipe]{
+            Message: message,
+            Data: *refreshed,
+            Status: http.StatusOK,
+        })
+    }
+}
+
+func RecipeHistoryHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        id, ok := recipeIdOf(ctx)
+        if !ok {
+            return
+        }
+
+        versions, err := RecipeHistory(sessData.AuthData.UserId, id)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't load the recipe history")
+            return
+        } else if versions == nil {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        }
+
+        ctx.JSON(http.StatusOK, models.Response[[]models.RecipeVersion]{
+            Message: "recipe history found",
+            Data: versions,
+            Status: http.StatusOK,
+        })
+    }
+}
+
+func DeleteRecipeHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        id, ok := recipeIdOf(ctx)
+        if !ok {
+            return
+        }
+
+        deleted, err := DeleteRecipe(sessData.AuthData.UserId, id)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't delete the recipe")
+            return
+        } else if !deleted {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        }
+
+        ctx.JSON(http.StatusOK, models.Response[interface{}]{
+            Message: "recipe deleted",
+            Data: nil,
+            Status: http.StatusOK,
+        })
+    }
+}
+
+func LogRecipeHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        // logs a saved recipe to Fitbit without scraping or building it again
+        sessData, ok := sessionOf(ctx)
+        if !ok {
+            return
+        }
+
+        id, ok := recipeIdOf(ctx)
+        if !ok {
+            return
+        }
+
+        req := models.LogRecipeRequest{Meal: models.Anytime, Amount: 1}
+        if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+            abortWith(ctx, http.StatusBadRequest, "doesn't follow expected input format")
+            return
+        }
+        if req.Amount <= 0 {
+            abortWith(ctx, http.StatusBadRequest, "amount must be a positive number")
+            return
+        }
+
+        saved, err := GetRecipe(sessData.AuthData.UserId, id)
+        if err != nil {
+            abortWith(ctx, http.StatusInternalServerError, "couldn't load the recipe")
+            return
+        } else if saved == nil {
+            abortWith(ctx, http.StatusNotFound, "recipe not found")
+            return
+        } else if saved.Recipe.Nutrition == nil {
+            abortWith(ctx, http.StatusUnprocessableEntity, "the recipe has no nutrition to log")
+            return
+        }
+
+        res, err := fitbit.LogFood(sessData.AuthData.UserId, sessData.AuthData.AccessToken, models.FoodLogRequest{
+            Name:       saved.Name,
+            Meal:       req.Meal,
+            UnitId:     fitbit.DefaultMeasurementId,
+            Amount:     req.Amount,
+            Nutrition:  saved.Recipe.Nutrition.Summary,
+        })
+        if err != nil {
+            log.Printf("[LIBRARY] log error: %+v", err)
+            abortWith(ctx, http.StatusBadGateway, fmt.Sprintf("%v", err))
+            return
+        }
+        defer res.Body.Close()
+
+        if res.StatusCode != http.StatusCreated {
+            body, _ := io.ReadAll(res.Body)
+            ctx.JSON(res.StatusCode, models.Response[string]{
+                Message: "failed to log food",
+                Data: string(body),
+                Status: res.StatusCode,
+            })
+            return
+        }
+
+        ctx.JSON(http.StatusCreated, models.Response[interface{}]{
+            Message: "food log added",
+            Data: nil,
+            Status: http.StatusCreated,
+        })
+    }
+}
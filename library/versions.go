@@ -0,0 +1,268 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	// logit libs
+	"logit/builder"
+	"logit/models"
+	"logit/parser"
+)
+
+// RecipeVersion is a row of a saved recipe's history, a copy of the recipe
+// every time it changed
+type RecipeVersion struct {
+    Id          uint        `gorm:"primaryKey"`
+    RecipeId    uint        `gorm:"index;not null"`
+    Version     int
+    Reason      string      `gorm:"size:16"`
+    Recipe      string      `gorm:"type:mediumtext"`
+    // models.RecipeDiff to the version before, empty for the first one
+    Changes     string      `gorm:"type:text"`
+    CreatedAt   time.Time
+}
+
+func addVersion(tx *gorm.DB, saved SavedRecipe, reason string, diff *models.RecipeDiff) error {
+    // REQUIRES:    tx, saved with its new Version and Recipe
+    // MODIFIES:    the database
+    // EFFECTS:     Records the current state of [saved] in its history
+
+    version := RecipeVersion{
+        RecipeId:   saved.Id,
+        Version:    saved.Version,
+        Reason:     reason,
+        Recipe:     saved.Recipe,
+    }
+
+    if diff != nil {
+        diffJSON, err := json.Marshal(diff)
+        if err != nil {
+            return err
+        }
+        version.Changes = string(diffJSON)
+    }
+
+    return tx.Create(&version).Error
+}
+
+func nextVersion(tx *gorm.DB, recipeId uint) (int, error) {
+    // the saved recipe's Version can be behind the history, see RefreshRecipe
+    var latest int
+    err := tx.Model(&RecipeVersion{}).
+        Select("COALESCE(MAX(version), 0)").
+        Where("recipe_id = ?", recipeId).
+        Scan(&latest).Error
+
+    return latest + 1, err
+}
+
+func unmarshalDiff(diffJSON string) (*models.RecipeDiff, error) {
+    if diffJSON == "" {
+        return nil, nil
+    }
+
+    var diff models.RecipeDiff
+    if err := json.Unmarshal([]byte(diffJSON), &diff); err != nil {
+        log.Printf("[LIBRARY] unmarshal error: %+v", err)
+        return nil, err
+    }
+
+    return &diff, nil
+}
+
+func (v RecipeVersion) toModel() (models.RecipeVersion, error) {
+    var recipe models.Recipe
+    if err := json.Unmarshal([]byte(v.Recipe), &recipe); err != nil {
+        log.Printf("[LIBRARY] unmarshal error: %+v", err)
+        return models.RecipeVersion{}, err
+    }
+
+    changes, err := unmarshalDiff(v.Changes)
+    if err != nil {
+        return models.RecipeVersion{}, err
+    }
+
+    return models.RecipeVersion{
+        Version:    v.Version,
+        Reason:     v.Reason,
+        Changes:    changes,
+        Recipe:     recipe,
+        CreatedAt:  v.CreatedAt,
+    }, nil
+}
+
+func RecipeHistory(userId string, id uint) ([]models.RecipeVersion, error) {
+    // REQUIRES:    userId
+    // MODIFIES:    none
+    // EFFECTS:     Returns every version of the user's recipe with [id], the
+    //              newest first, or nil without an error if they don't have
+    //              one
+
+    row, err := getRow(userId, id)
+    if row == nil {
+        return nil, err
+    }
+
+    var rows []RecipeVersion
+    if err := builder.Db.Where("recipe_id = ?", row.Id).Order("version desc").Find(&rows).Error; err != nil {
+        log.Printf("[LIBRARY] history error: %+v", err)
+        return nil, err
+    }
+
+    versions := make([]models.RecipeVersion, 0, len(rows))
+    for _, row := range rows {
+        version, err := row.toModel()
+        if err != nil {
+            return nil, err
+        }
+        versions = append(versions, version)
+    }
+
+    return versions, nil
+}
+
+func lastScrapedVersion(recipeId uint) (*models.Recipe, error) {
+    // the user's edits aren't changes of the site, so a re-scrape is
+    // compared with the last version that came from the site
+    var row RecipeVersion
+    err := builder.Db.
+        Where("recipe_id = ? AND reason IN ?", recipeId, []string{models.VERSION_SAVED, models.VERSION_RESCRAPED}).
+        Order("version desc").
+        First(&row).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, nil
+    } else if err != nil {
+        return nil, err
+    }
+
+    version, err := row.toModel()
+    if err != nil {
+        return nil, err
+    }
+
+    return &version.Recipe, nil
+}
+
+func isEdited(row SavedRecipe) (bool, error) {
+    // whether the saved recipe is one the user edited, rather than the one
+    // the site gave in the servings they picked
+    if row.Edited {
+        return true, nil
+    }
+
+    var version RecipeVersion
+    err := builder.Db.Where("recipe_id = ? AND version = ?", row.Id, row.Version).First(&version).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return false, nil
+    } else if err != nil {
+        return false, err
+    }
+
+    return version.Reason == models.VERSION_EDITED, nil
+}
+
+func RefreshRecipe(userId string, id uint, scraped models.Recipe) (*models.LibraryRecipe, *models.RecipeDiff, error) {
+    // REQUIRES:    userId, scraped is the complete recipe freshly scraped
+    //              from the saved recipe's link, not a PARTIAL_RECIPE
+    // MODIFIES:    the database
+    // EFFECTS:     Compares [scraped] with what the site gave the last time.
+    //              If the site changed the recipe, it's recorded as a new
+    //              version and the recipe is flagged as Changed. It replaces
+    //              the saved one, divided into the servings the user picked,
+    //              unless the user edited the saved one, which is kept.
+    //              Returns the saved recipe and the changes, which are nil if
+    //              there were none, or a nil recipe if the user doesn't have
+    //              one
+
+    row, err := getRow(userId, id)
+    if row == nil {
+        return nil, nil, err
+    }
+
+    current, err := row.toModel()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    previous, err := lastScrapedVersion(row.Id)
+    if err != nil {
+        log.Printf("[LIBRARY] history error: %+v", err)
+        return nil, nil, err
+    }
+
+    // recipes saved before there was a history only have the current one
+    if previous == nil {
+        previous = &current.Recipe
+    }
+
+    diff := parser.DiffRecipes(*previous, scraped)
+    if diff.IsEmpty() {
+        return &current, nil, nil
+    }
+
+    edited, err := isEdited(*row)
+    if err != nil {
+        log.Printf("[LIBRARY] history error: %+v", err)
+        return nil, nil, err
+    }
+
+    recipeJSON, err := json.Marshal(scraped)
+    if err != nil {
+        return nil, nil, err
+    }
+    diffJSON, err := json.Marshal(diff)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    // the history keeps the recipe as the site has it, the saved recipe
+    // keeps the user's servings
+    version := *row
+    version.Recipe = string(recipeJSON)
+
+    if current.Recipe.Servings > 0 && current.Recipe.Servings != scraped.Servings {
+        parser.RescaleServings(&scraped, current.Recipe.Servings)
+        if recipeJSON, err = json.Marshal(scraped); err != nil {
+            return nil, nil, err
+        }
+    }
+
+    err = builder.Db.Transaction(func(tx *gorm.DB) error {
+        next, err := nextVersion(tx, row.Id)
+        if err != nil {
+            return err
+        }
+
+        version.Version = next
+        if err := addVersion(tx, version, models.VERSION_RESCRAPED, &diff); err != nil {
+            return err
+        }
+
+        // the user's edits aren't thrown away, they only get to see what
+        // the site changed
+        if !edited {
+            row.Recipe = string(recipeJSON)
+            row.Version = version.Version
+            row.Edited = false
+        }
+        row.Changed = true
+        row.Changes = string(diffJSON)
+        return tx.Save(row).Error
+    })
+    if err != nil {
+        log.Printf("[LIBRARY] refresh error: %+v", err)
+        return nil, nil, err
+    }
+
+    refreshed, err := row.toModel()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    return &refreshed, &diff, nil
+}
@@ -0,0 +1,101 @@
+package library
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"logit/builder"
+	"logit/models"
+	"logit/parser"
+)
+
+func openTestDB(t *testing.T) {
+    // a database of its own for every test, shared by the pool's connections
+    db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    saved := builder.Db
+    builder.Db = db
+    t.Cleanup(func() { builder.Db = saved })
+
+    if err := Migrate(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func testRecipe(servings float64, ingredients ...string) models.Recipe {
+    recipe := models.Recipe{Servings: servings, Ingredients: ingredients}
+    parser.SetTotalNutrition(&recipe, models.Nutrition{Calories: 1200})
+    return recipe
+}
+
+func TestRefreshAfterServings(t *testing.T) {
+    openTestDB(t)
+
+    saved, err := SaveRecipe("user", models.SOURCE_SCRAPE, "https://example.com/chili", testRecipe(4, "1 lb beef", "1 onion"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // only the servings change, the recipe is still the site's
+    rescaled := saved.Recipe
+    parser.RescaleServings(&rescaled, 6)
+    if _, err := UpdateRecipe("user", saved.Id, saved.Name, rescaled, models.VERSION_RESCALED, false); err != nil {
+        t.Fatal(err)
+    }
+
+    scraped := testRecipe(4, "1 lb beef", "1 onion", "2 cups beans")
+    refreshed, diff, err := RefreshRecipe("user", saved.Id, scraped)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if diff == nil || !reflect.DeepEqual(diff.AddedIngredients, []string{"2 cups beans"}) {
+        t.Fatalf("diff = %+v, want the beans added", diff)
+    }
+
+    if !reflect.DeepEqual(refreshed.Recipe.Ingredients, scraped.Ingredients) {
+        t.Errorf("ingredients = %v, want the refreshed %v", refreshed.Recipe.Ingredients, scraped.Ingredients)
+    }
+    if refreshed.Recipe.Servings != 6 {
+        t.Errorf("servings = %v, want the user's 6", refreshed.Recipe.Servings)
+    }
+    if !refreshed.Changed {
+        t.Errorf("refreshed recipe isn't flagged as Changed")
+    }
+}
+
+func TestRefreshKeepsEdits(t *testing.T) {
+    openTestDB(t)
+
+    saved, err := SaveRecipe("user", models.SOURCE_SCRAPE, "https://example.com/chili", testRecipe(4, "1 lb beef", "1 onion"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    edited := testRecipe(4, "1 lb turkey", "1 onion")
+    if _, err := UpdateRecipe("user", saved.Id, saved.Name, edited, models.VERSION_EDITED, false); err != nil {
+        t.Fatal(err)
+    }
+
+    // rescaling an edited recipe doesn't make it the site's again
+    parser.RescaleServings(&edited, 2)
+    if _, err := UpdateRecipe("user", saved.Id, saved.Name, edited, models.VERSION_RESCALED, false); err != nil {
+        t.Fatal(err)
+    }
+
+    refreshed, diff, err := RefreshRecipe("user", saved.Id, testRecipe(4, "1 lb beef", "1 onion", "2 cups beans"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if diff == nil || !refreshed.Changed {
+        t.Fatalf("refresh didn't report the site's changes")
+    }
+    if !reflect.DeepEqual(refreshed.Recipe.Ingredients, edited.Ingredients) {
+        t.Errorf("ingredients = %v, want the user's %v", refreshed.Recipe.Ingredients, edited.Ingredients)
+    }
+}
@@ -18,4 +18,6 @@ type Response[T any] struct {
     Message     string      `json:"message"`
     Data        T           `json:"data"`
     Status      int         `json:"status"`
+    // machine readable reason when something went wrong, e.g. "no_recipe_found"
+    Code        string      `json:"code,omitempty"`
 }
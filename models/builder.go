@@ -6,14 +6,80 @@ type IngredientParseRequest struct {
 }
 
 type RecipeBuilderResponse struct {
-    Nutrition   Nutrition    `json:"nutrition"` 
-    Errors      []string     `json:"errors"`
+    Nutrition   Nutrition               `json:"nutrition"` 
+    Errors      []string                `json:"errors"`
+    Matches     []IngredientMatch       `json:"matches"`
+    Breakdown   []IngredientBreakdown   `json:"breakdown"`
 }
 
-// IngredientParseResponse
+// the USDA portion an amount was weighed with, "1 cup" is 128 g of flour
+type FoodPortion struct {
+    Amount      float32     `json:"amount"`
+    Unit        string      `json:"unit"`
+    GramWeight  float32     `json:"gram_weight"`
+}
+
+// what one line of the ingredient list added to the recipe. Lines that
+// weren't counted have an Error saying why and no nutrition
+type IngredientBreakdown struct {
+    Text        string          `json:"text"`
+    Ingredient  Ingredient      `json:"ingredient"`
+    FdcId       int             `json:"fdc_id,omitempty"`
+    Description string          `json:"description,omitempty"`
+    Portion     *FoodPortion    `json:"portion"`
+    GramWeight  float64         `json:"gram_weight"`
+    Nutrition   Nutrition       `json:"nutrition"`
+    Error       string          `json:"error,omitempty"`
+}
+
+// a USDA food an ingredient could be, Score is between 0 and 1
+type FoodCandidate struct {
+    FdcId       int         `json:"fdc_id"`
+    Description string      `json:"description"`
+    Score       float64     `json:"score"`
+}
+
+// the food the builder picked for an ingredient, nil if nothing came close,
+// and the runners up the user can pick instead
+type IngredientMatch struct {
+    Ingredient      string              `json:"ingredient"`
+    Food            *FoodCandidate      `json:"food"`
+    Alternatives    []FoodCandidate     `json:"alternatives"`
+}
+
+// a whole recipe pasted as plain text
+type RecipeTextRequest struct {
+    Text        string      `json:"text"`
+}
+
+// the recipe found in the text, with the nutrition the builder computed
+// for its ingredients. Errors are the ingredients that weren't counted, the
+// breakdown has the whole recipe's amounts, not a serving's
+type RecipeTextResponse struct {
+    Recipe      Recipe                  `json:"recipe"`
+    Errors      []string                `json:"errors"`
+    Breakdown   []IngredientBreakdown   `json:"breakdown"`
+}
+
+// how an amount after the first relates to it
+const (
+    // the same amount in other units, "1 cup (240 ml)" or "1 cup / 240 ml"
+    AMOUNT_ALTERNATIVE  = "alternative"
+    // a part of the amount, "2 lb 4 oz" or "1 tbsp + 1 tsp"
+    AMOUNT_ADDITIVE     = "additive"
+    // what each of the first amount holds, "1 (14 oz) can" or "2 15-ounce
+    // cans"
+    AMOUNT_PER_UNIT     = "per_unit"
+)
+
+// IngredientParseResponse. A range like "2-3 cups" has the upper bound in
+// UpperValue. Relation is empty for the first amount and one of the
+// AMOUNT_* for the others, also empty if the parser service didn't say
 type Amount struct {
-    Unit    string      `json:"unit"`
-    Value   float32     `json:"value"`
+    Unit        string      `json:"unit"`
+    Value       float32     `json:"value"`
+    UpperValue  float32     `json:"upper_value,omitempty"`
+    Relation    string      `json:"relation,omitempty"`
 }
 
 type Ingredient struct {
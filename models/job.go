@@ -0,0 +1,37 @@
+package models
+
+import (
+    "encoding/json"
+    "time"
+)
+
+// Job is a scrape, OCR or build request that runs in the background
+type Job struct {
+    Id          string          `json:"id"`
+    Kind        string          `json:"kind"`
+    // queued, running, done or failed
+    State       string          `json:"state"`
+    // 0 to 100, with a short description of what the job is doing
+    Progress    int             `json:"progress"`
+    Step        string          `json:"step"`
+    // the request the job was submitted with, never sent to the client
+    Payload     json.RawMessage `json:"payload,omitempty"`
+    // the models.Response the synchronous endpoint would have returned
+    Result      json.RawMessage `json:"result,omitempty"`
+    CreatedAt   time.Time       `json:"createdAt"`
+    UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// the query of the calculate endpoints. All returns every recipe on the
+// page, Index (from 0) or Name select one of them
+type ScrapeJobRequest struct {
+    Link        string      `json:"link"`
+    Servings    float64     `json:"servings"`
+    All         bool        `json:"all,omitempty"`
+    Index       *int        `json:"index,omitempty"`
+    Name        string      `json:"name,omitempty"`
+}
+
+type ImageJobRequest struct {
+    Image       []byte      `json:"image"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+    "time"
+)
+
+// where a saved recipe came from
+const (
+    SOURCE_SCRAPE   = "scrape"
+    SOURCE_BUILD    = "build"
+    SOURCE_TEXT     = "text"
+)
+
+// a recipe in a user's library
+type LibraryRecipe struct {
+    Id          uint        `json:"id"`
+    Name        string      `json:"name"`
+    Source      string      `json:"source"`
+    // the page the recipe was scraped from, empty for built recipes
+    Link        string      `json:"link"`
+    Recipe      Recipe      `json:"recipe"`
+    Version     int         `json:"version"`
+    // set when re-scraping the link changed the recipe, until the user has
+    // seen Changes
+    Changed     bool        `json:"changed"`
+    Changes     *RecipeDiff `json:"changes,omitempty"`
+    CreatedAt   time.Time   `json:"createdAt"`
+    UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+// saves the recipe the app got from /calculate or the builder. With only a
+// link, the recipe is fetched (usually from the cache) instead
+type SaveRecipeRequest struct {
+    Source      string      `json:"source"`
+    Link        string      `json:"link"`
+    Recipe      *Recipe     `json:"recipe"`
+}
+
+// every field is optional. Changing the ingredients recomputes the
+// nutrition with the recipe builder. AcknowledgeChanges clears Changed
+type UpdateRecipeRequest struct {
+    Name                *string     `json:"name"`
+    Servings            *float64    `json:"servings"`
+    Ingredients         []string    `json:"ingredients"`
+    AcknowledgeChanges  bool        `json:"acknowledgeChanges"`
+}
+
+// Errors are the ingredients the builder couldn't count after an edit
+type UpdateRecipeResponse struct {
+    Recipe      LibraryRecipe   `json:"recipe"`
+    Errors      []string        `json:"errors"`
+}
+
+// logs [Amount] servings of a saved recipe
+type LogRecipeRequest struct {
+    Meal        MealType    `json:"mealTypeId"`
+    Amount      float64     `json:"amount"`
+}
+
+// a value before and after a recipe changed
+type ValueChange struct {
+    Old         float64     `json:"old"`
+    New         float64     `json:"new"`
+}
+
+// what changed between two versions of a recipe. Nutrition is per serving
+// and keyed like NutritionInfo.Nutrients
+type RecipeDiff struct {
+    AddedIngredients    []string                `json:"addedIngredients"`
+    RemovedIngredients  []string                `json:"removedIngredients"`
+    Servings            *ValueChange            `json:"servings,omitempty"`
+    Nutrition           map[string]ValueChange  `json:"nutrition"`
+}
+
+func (d RecipeDiff) IsEmpty() bool {
+    return len(d.AddedIngredients) == 0 && len(d.RemovedIngredients) == 0 &&
+        d.Servings == nil && len(d.Nutrition) == 0
+}
+
+// why a version of a saved recipe was made
+const (
+    VERSION_SAVED       = "saved"
+    VERSION_EDITED      = "edited"
+    // only the servings changed, the recipe is still the site's
+    VERSION_RESCALED    = "rescaled"
+    VERSION_RESCRAPED   = "rescraped"
+)
+
+// a past version of a saved recipe. Changes is what changed compared to
+// the version before it, or for re-scrapes to the last version from the
+// site, nil for the first one
+type RecipeVersion struct {
+    Version     int             `json:"version"`
+    Reason      string          `json:"reason"`
+    Changes     *RecipeDiff     `json:"changes"`
+    Recipe      Recipe          `json:"recipe"`
+    CreatedAt   time.Time       `json:"createdAt"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Thing struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
@@ -7,13 +9,125 @@ type Thing struct {
 	Image       interface{} `json:"image"`
 }
 
+// schema.org/Person or schema.org/Organization
+type Author struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// schema.org/AggregateRating
+type AggregateRating struct {
+	RatingValue float64 `json:"ratingValue"`
+	RatingCount int     `json:"ratingCount"`
+	ReviewCount int     `json:"reviewCount"`
+	BestRating  float64 `json:"bestRating"`
+	WorstRating float64 `json:"worstRating"`
+}
+
+// schema.org/HowToStep, flattened out of any HowToSection nesting.
+// Section is the name of the HowToSection the step belonged to
+type InstructionStep struct {
+	Position int    `json:"position"`
+	Section  string `json:"section"`
+	Name     string `json:"name"`
+	Text     string `json:"text"`
+	Url      string `json:"url"`
+}
+
+// an image of the recipe. Width and Height are 0 when the site didn't say
+type ImageCandidate struct {
+	Url    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// a cookTime/prepTime/totalTime value converted into minutes. Valid is
+// false when Raw was present but couldn't be understood
+type Duration struct {
+	Raw      string `json:"raw"`
+	Minutes  int    `json:"minutes"`
+	Text     string `json:"text"`
+	Valid    bool   `json:"valid"`
+	Computed bool   `json:"computed"`
+}
+
+type RecipeTimes struct {
+	Prep   Duration `json:"prep"`
+	Cook   Duration `json:"cook"`
+	Total  Duration `json:"total"`
+	Errors []string `json:"errors"`
+}
+
+// a single NutritionInformation value. Qty is converted into Unit, the
+// unit models.Nutrition expects for that nutrient
+type Nutrient struct {
+	Name     string  `json:"name"`
+	Qty      float64 `json:"qty"`
+	Unit     string  `json:"unit"`
+	LessThan bool    `json:"lessThan"`
+	Raw      string  `json:"raw"`
+}
+
+// schema.org/NutritionInformation. Summary holds the same values in the
+// shape /log and /create accept. Servings is how many servings the values
+// cover, 1 for per serving nutrition
+type NutritionInfo struct {
+	ServingSize string              `json:"servingSize"`
+	Servings    float64             `json:"servings"`
+	Nutrients   map[string]Nutrient `json:"nutrients"`
+	Summary     Nutrition           `json:"summary"`
+}
+
 // schema.org/recipe
 type Recipe struct {
-	CookTime    string      `json:"cookTime"`
-	PrepTime    string      `json:"prepTime"`
-	TotalTime   string      `json:"totalTime"`
-	Nutrition   interface{} `json:"nutrition"`
-	Ingredients interface{} `json:"recipeIngredient"`
+	CookTime        string            `json:"cookTime"`
+	PrepTime        string            `json:"prepTime"`
+	TotalTime       string            `json:"totalTime"`
+	Times           RecipeTimes       `json:"times"`
+	Nutrition       *NutritionInfo    `json:"nutrition"`
+	TotalNutrition  *NutritionInfo    `json:"totalNutrition"`
+	Servings        float64           `json:"servings"`
+	Ingredients     []string          `json:"recipeIngredient"`
+	Instructions    []InstructionStep `json:"recipeInstructions"`
+	Yield           []string          `json:"recipeYield"`
+	Category        []string          `json:"recipeCategory"`
+	Cuisine         []string          `json:"recipeCuisine"`
+	Keywords        []string          `json:"keywords"`
+	Author          []Author          `json:"author"`
+	DatePublished   string            `json:"datePublished"`
+	AggregateRating *AggregateRating  `json:"aggregateRating"`
+	// every image the recipe has, best first. Image is the first one
+	Images []ImageCandidate `json:"images"`
 	Thing
+
+	// set when the recipe was pieced together from Open Graph tags and
+	// ingredient lists because the page had no structured data
+	LowConfidence bool `json:"lowConfidence"`
 }
 
+// a scraped recipe stored by the recipe cache, along with every recipe on
+// its page (Recipe among them) and the validators needed to revalidate it
+// once it goes stale
+type CachedRecipe struct {
+	Url          string    `json:"url"`
+	Recipe       Recipe    `json:"recipe"`
+	Recipes      []Recipe  `json:"recipes"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"lastModified"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// BatchRequest is the body of the batch import endpoint
+type BatchRequest struct {
+	Links []string `json:"links" binding:"required"`
+}
+
+// BatchResult is the outcome for one link of a batch, with the same status
+// and code the single link endpoint would respond with
+type BatchResult struct {
+	Link    string  `json:"link"`
+	Recipe  *Recipe `json:"recipe"`
+	Status  int     `json:"status"`
+	Code    string  `json:"code,omitempty"`
+	Message string  `json:"message,omitempty"`
+}
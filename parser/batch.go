@@ -0,0 +1,100 @@
+package parser
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gocolly/colly"
+
+    "logit/models"
+)
+
+// most links accepted in one batch request
+const MAX_BATCH_SIZE = 50
+
+// a link of the batch that is scraped, shared by links with the same key
+type batchVisit struct {
+    key     string
+    result  *scrapeResult
+    recipe  models.Recipe
+    err     error
+}
+
+func FetchRecipes(links []string, uagents []string) []models.BatchResult {
+    // REQUIRES:    links, uagents
+    // MODIFIES:    Cache
+    // EFFECTS:     Returns a result for every link in [links], in the same
+    //              order. Fresh cache entries are used as is, the rest is
+    //              scraped with one shared async collector, which the crawl
+    //              policy limits per site. Links with the same canonical URL
+    //              are only scraped once
+
+    results := make([]models.BatchResult, len(links))
+    visits := map[string]*batchVisit{}
+    pending := make([]*batchVisit, len(links))
+
+    c := newCollector(uagents, colly.Async(true))
+
+    for i, link := range links {
+        results[i].Link = link
+
+        key, cached, err := lookupRecipe(link)
+        if err != nil {
+            setBatchResult(&results[i], models.Recipe{}, err)
+            continue
+        }
+
+        if IsFresh(cached) {
+            setBatchResult(&results[i], cached.Recipe, partialRecipeError(cached.Recipe))
+            continue
+        }
+
+        if v, exists := visits[key]; exists {
+            pending[i] = v
+            continue
+        }
+
+        v := &batchVisit{key: key, result: visit(c, link, cached)}
+        visits[key] = v
+        pending[i] = v
+    }
+
+    c.Wait()
+
+    for _, v := range visits {
+        v.recipe, v.err = finishRecipe(v.key, v.result)
+    }
+
+    for i, v := range pending {
+        if v != nil {
+            setBatchResult(&results[i], v.recipe, v.err)
+        }
+    }
+
+    return results
+}
+
+func setBatchResult(result *models.BatchResult, recipe models.Recipe, err error) {
+    // REQUIRES:    result, err from FetchRecipe or finishRecipe
+    // MODIFIES:    result
+    // EFFECTS:     Fills in [result] like CalculateHandler would respond for
+    //              a single link. Partial recipes keep the recipe and the code
+
+    result.Status = http.StatusOK
+
+    var scrapeErr *ScrapeError
+    if errors.As(err, &scrapeErr) {
+        result.Status = scrapeErr.Status
+        result.Code = scrapeErr.Code
+        result.Message = scrapeErr.Message
+        if scrapeErr.Code != PARTIAL_RECIPE {
+            return
+        }
+    } else if err != nil {
+        result.Status = http.StatusInternalServerError
+        result.Message = err.Error()
+        return
+    }
+
+    result.Recipe = &recipe
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+
+    "logit/models"
+)
+
+// posts [body] to a BatchHandler and decodes what it responds
+func postBatch(t *testing.T, body string) (int, models.Response[[]models.BatchResult]) {
+    gin.SetMode(gin.TestMode)
+    router := gin.New()
+    router.POST("/batch", BatchHandler([]string{"logit-tests"}))
+
+    recorder := httptest.NewRecorder()
+    router.ServeHTTP(recorder, httptest.NewRequest("POST", "/batch", strings.NewReader(body)))
+
+    var response models.Response[[]models.BatchResult]
+    if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+        t.Fatalf("couldn't decode %q: %+v", recorder.Body.String(), err)
+    }
+
+    return recorder.Code, response
+}
+
+func TestBatchHandler(t *testing.T) {
+    var lock sync.Mutex
+    requests := map[string]int{}
+    files := http.FileServer(http.Dir(FIXTURE_DIR))
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        lock.Lock()
+        requests[r.URL.Path]++
+        lock.Unlock()
+        files.ServeHTTP(w, r)
+    }))
+    defer server.Close()
+
+    links := []string{
+        server.URL + "/microdata.html",
+        server.URL + "/partial.html",
+        server.URL + "/no_recipe.html",
+        server.URL + "/missing.html",
+        "ftp://example.com/recipe",
+        // the same page as the first, it's only scraped once
+        server.URL + "/microdata.html?utm_source=newsletter",
+    }
+    want := []struct {
+        status  int
+        code    string
+        recipe  bool
+    }{
+        {http.StatusOK, "", true},
+        {http.StatusOK, PARTIAL_RECIPE, true},
+        {http.StatusUnprocessableEntity, NO_RECIPE_FOUND, false},
+        {http.StatusBadGateway, FETCH_FAILED, false},
+        {http.StatusBadRequest, INVALID_URL, false},
+        {http.StatusOK, "", true},
+    }
+
+    withCrawl(t, localCrawl(), func() {
+        Cache = NewMemoryCache()
+
+        body, _ := json.Marshal(models.BatchRequest{Links: links})
+        status, response := postBatch(t, string(body))
+        if status != http.StatusOK {
+            t.Fatalf("status = %d, want %d", status, http.StatusOK)
+        }
+        if len(response.Data) != len(links) {
+            t.Fatalf("%d results, want %d", len(response.Data), len(links))
+        }
+
+        for i, result := range response.Data {
+            if result.Link != links[i] {
+                t.Errorf("result %d is for %s, want %s", i, result.Link, links[i])
+            }
+            if result.Status != want[i].status || result.Code != want[i].code || (result.Recipe != nil) != want[i].recipe {
+                t.Errorf("%s = %d %q, recipe %v, want %d %q, recipe %v", links[i], result.Status, result.Code,
+                    result.Recipe != nil, want[i].status, want[i].code, want[i].recipe)
+            }
+        }
+        if first, duplicate := response.Data[0].Recipe, response.Data[5].Recipe; first != nil && duplicate != nil && duplicate.Name != first.Name {
+            t.Errorf("the duplicate link got %q, want %q", duplicate.Name, first.Name)
+        }
+
+        lock.Lock()
+        defer lock.Unlock()
+        if requests["/microdata.html"] != 1 {
+            t.Errorf("the same page was requested %d times, want once", requests["/microdata.html"])
+        }
+    })
+}
+
+func TestBatchHandlerRejects(t *testing.T) {
+    many, _ := json.Marshal(models.BatchRequest{Links: make([]string, MAX_BATCH_SIZE+1)})
+
+    for _, body := range []string{"", "{}", `{"links": []}`, `{"links": "https://example.com"}`, string(many)} {
+        status, response := postBatch(t, body)
+        if status != http.StatusBadRequest || response.Data != nil {
+            t.Errorf("%.40q = %d, %v, want %d without results", body, status, response.Data, http.StatusBadRequest)
+        }
+    }
+}
@@ -0,0 +1,199 @@
+package parser
+
+import (
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "logit/models"
+)
+
+const (
+    // how long a scraped recipe is served without asking the site again
+    CACHE_FRESHNESS = 1 * time.Hour
+    // how long a stale recipe is kept around so it can be revalidated with
+    // its ETag or Last-Modified instead of being scraped from scratch
+    CACHE_EXPIRY = 7 * 24 * time.Hour
+    // most recipes MemoryCache holds, the ones closest to expiring make room
+    MEMORY_CACHE_SIZE = 1000
+    // how often Set drops the expired recipes nobody asked for again
+    MEMORY_CACHE_SWEEP = 10 * time.Minute
+)
+
+// RecipeCache stores scraped recipes by canonical URL. Get returns nil
+// without an error when the key isn't cached. redis.RecipeCache implements
+// it on top of the shared redis client
+type RecipeCache interface {
+    Get(key string) (*models.CachedRecipe, error)
+    Set(key string, entry models.CachedRecipe, ttl time.Duration) error
+}
+
+// Cache is used by CalculateHandler and BatchHandler. It defaults to an in-memory cache and
+// can be swapped for redis.RecipeCache{} once redis is configured
+var Cache RecipeCache = NewMemoryCache()
+
+// query parameters that only track where a visitor came from
+var trackingParams = map[string]bool{
+    "fbclid": true, "gclid": true, "dclid": true, "msclkid": true,
+    "yclid": true, "igshid": true, "mc_cid": true, "mc_eid": true,
+    "_ga": true, "_gl": true, "ref": true, "ref_src": true, "spm": true,
+}
+
+func CanonicalizeURL(link string) (string, error) {
+    // REQUIRES:    link
+    // MODIFIES:    none
+    // EFFECTS:     Normalizes [link] so that the same page shared from
+    //              different places maps onto the same cache key. Lowercases the
+    //              host, drops default ports, fragments, tracking parameters and
+    //              trailing slashes, and sorts the remaining query
+
+    u, err := url.Parse(strings.TrimSpace(link))
+    if err != nil {
+        return "", err
+    }
+
+    u.Scheme = strings.ToLower(u.Scheme)
+    u.Host = strings.ToLower(u.Host)
+    if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+        u.Host = u.Hostname()
+    }
+
+    u.Fragment = ""
+    u.RawFragment = ""
+    u.User = nil
+
+    if len(u.Path) > 1 {
+        u.Path = strings.TrimRight(u.Path, "/")
+        u.RawPath = ""
+    }
+
+    query := u.Query()
+    for key := range query {
+        if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+            query.Del(key)
+        }
+    }
+    // Encode sorts by key
+    u.RawQuery = query.Encode()
+
+    return u.String(), nil
+}
+
+func IsFresh(entry *models.CachedRecipe) bool {
+    return entry != nil && time.Since(entry.FetchedAt) < CACHE_FRESHNESS
+}
+
+func cacheKeys(keys ...string) []string {
+    // canonicalizes and dedupes the keys a recipe is stored under
+    seen := map[string]bool{}
+    var canonical []string
+    for _, key := range keys {
+        if key == "" {
+            continue
+        }
+        if c, err := CanonicalizeURL(key); err == nil && !seen[c] {
+            seen[c] = true
+            canonical = append(canonical, c)
+        }
+    }
+
+    return canonical
+}
+
+type memoryEntry struct {
+    entry   models.CachedRecipe
+    expires time.Time
+}
+
+// MemoryCache is a RecipeCache local to this process. It holds at most
+// size entries
+type MemoryCache struct {
+    entries     map[string]memoryEntry
+    size        int
+    lastSweep   time.Time
+    lock        sync.RWMutex
+}
+
+func NewMemoryCache() *MemoryCache {
+    return newMemoryCache(MEMORY_CACHE_SIZE)
+}
+
+func newMemoryCache(size int) *MemoryCache {
+    return &MemoryCache{
+        entries:    map[string]memoryEntry{},
+        size:       size,
+        lastSweep:  time.Now(),
+    }
+}
+
+func (m *MemoryCache) Get(key string) (*models.CachedRecipe, error) {
+    m.lock.RLock()
+    cached, exists := m.entries[key]
+    m.lock.RUnlock()
+
+    if !exists {
+        return nil, nil
+    }
+
+    if time.Now().After(cached.expires) {
+        m.lock.Lock()
+        delete(m.entries, key)
+        m.lock.Unlock()
+        return nil, nil
+    }
+
+    entry := cached.entry
+    return &entry, nil
+}
+
+func (m *MemoryCache) Set(key string, entry models.CachedRecipe, ttl time.Duration) error {
+    m.lock.Lock()
+    defer m.lock.Unlock()
+
+    now := time.Now()
+    if now.Sub(m.lastSweep) >= MEMORY_CACHE_SWEEP {
+        m.sweep(now)
+    }
+    if _, exists := m.entries[key]; !exists && len(m.entries) >= m.size {
+        m.sweep(now)
+        if len(m.entries) >= m.size {
+            m.evict()
+        }
+    }
+
+    m.entries[key] = memoryEntry{
+        entry:      entry,
+        expires:    time.Now().Add(ttl),
+    }
+
+    return nil
+}
+
+func (m *MemoryCache) sweep(now time.Time) {
+    // REQUIRES:    m.lock is held
+    // MODIFIES:    m
+    // EFFECTS:     Drops every expired entry
+
+    for key, cached := range m.entries {
+        if now.After(cached.expires) {
+            delete(m.entries, key)
+        }
+    }
+    m.lastSweep = now
+}
+
+func (m *MemoryCache) evict() {
+    // REQUIRES:    m.lock is held
+    // MODIFIES:    m
+    // EFFECTS:     Drops the entry that expires first. Entries expire a fixed
+    //              time after they're stored, so it's the one stored longest ago
+
+    oldest := ""
+    for key, cached := range m.entries {
+        if oldest == "" || cached.expires.Before(m.entries[oldest].expires) {
+            oldest = key
+        }
+    }
+    delete(m.entries, oldest)
+}
@@ -5,15 +5,10 @@ import (
     "bufio"
     "strings"
     "log"
-    "math/rand"
     "golang.org/x/text/cases"
     "golang.org/x/text/language"
-    "regexp"
-    "strconv"
 )
 
-const QTY_REGEX = `[0-9]+\.*[0-9]*`
-
 func LoadUagents(uagents *[]string) error {
     f, err := os.Open("/usr/src/backend/uagents.txt")
     if err != nil {
@@ -36,7 +31,7 @@ func LoadUagents(uagents *[]string) error {
 
 func GetUnit(key string) string {
     if key == "calories" {
-        return "cals"
+        return "kcal"
     } else if key == "carbohydrateContent" ||
     key == "fatContent" || key == "fiberContent" ||
     key == "proteinContent" || key == "sugarContent" ||
@@ -69,20 +64,32 @@ func CreateName(key string) string {
 func FindRecipe(json interface{}) map[string]interface{} {
     // REQUIRES:    json
     // MODIFIES:    none
-    // EFFECTS:     Checks the type of the json (using type coercion) and then
-    //              gets the recipe schema based on that type
+    // EFFECTS:     Returns the first recipe schema in [json], or nil if it has
+    //              none. See FindRecipes
 
-    switch json := json.(type) {
-    case map[string]interface{}:
-        return findInMap(json)
-    case []interface{}:
-        return findInInterfaceList(json)
-    case []map[string]interface{}:
-        return findInMapList(json)
-    default:
-        log.Printf("[PARSER] recipe error: encountered unexpected type %T\n", json)
-        return nil
+    if recipes := FindRecipes(json); len(recipes) > 0 {
+        return recipes[0]
     }
+
+    return nil
+}
+
+// nodes that hold other nodes, a roundup lists its recipes in an ItemList
+// and a page can wrap its recipe in the WebPage's mainEntity
+var schemaContainerKeys = []string{"@graph", "mainEntity", "itemListElement", "item", "hasPart"}
+
+func FindRecipes(json interface{}) []map[string]interface{} {
+    // REQUIRES:    json
+    // MODIFIES:    none
+    // EFFECTS:     Checks the type of the json (using type coercion) and
+    //              returns every recipe schema in it, in the order the page
+    //              lists them. Looks through @graph, lists and the nodes
+    //              in schemaContainerKeys, but not inside a recipe
+
+    var recipes []map[string]interface{}
+    collectRecipes(json, &recipes)
+
+    return recipes
 }
 
 func isRecipeSchema (schemaType interface{}) bool {
@@ -113,149 +120,32 @@ func isRecipeSchema (schemaType interface{}) bool {
     return false
 } 
 
-func findInMap(json map[string]interface{}) map[string]interface{} {
-    // REQUIRES:    json
-    // MODIFIES:    none
-    // EFFECTS:     When the JSON is a map, it will either be a map containing 
-    //              a list or the regular map. If it contains a list, we should
-    //              loop through the list and find the desired schema. If it's
-    //              just a regular map, we should make sure the map is a holding
-    //              recipe data
-
-    if nodeArray, exists := json["@graph"]; exists {
-        return FindRecipe(nodeArray) 
-    }
-    
-    if schemaType, exists := json["@type"]; exists {
-        if isRecipeSchema(schemaType) {
-            return json
-        }
-    }
-    
-    return nil
-}
-
-func findInInterfaceList(json []interface{}) map[string]interface{} {
-    // REQUIRES:    json 
-    // MODIFIES:    none
-    // EFFECTS:     When the JSON is a map, it will either be a map containing 
-    //              a list or the regular map. If it contains a list, we should
-    //              loop through the list and find the desired schema. If it's
-    //              just a regular map, we should make sure the map is a holding
-    //              recipe data
-
-    for _, schema := range json {
-        if schema, ok := schema.(map[string]interface{}); ok {
-            if schemaType, exists := schema["@type"]; exists {
-                if isRecipeSchema(schemaType) {
-                    return schema 
-                }
-            }
-        }
-    }
-    
-    return nil
-}
-
-func findInMapList(json []map[string]interface{}) map[string]interface{} {
-    // REQUIRES:    json 
-    // MODIFIES:    none
-    // EFFECTS:     When the JSON is a map, it will either be a map containing 
-    //              a list or the regular map. If it contains a list, we should
-    //              loop through the list and find the desired schema. If it's
-    //              just a regular map, we should make sure the map is a holding
-    //              recipe data
-
-    for _, schema := range json {
-        if schemaType, exists := schema["@type"]; exists && isRecipeSchema(schemaType) {
-            if isRecipeSchema(schemaType) {
-                return schema 
-            }
-        }
-    }
-
-    return nil
-}
-
-func BuildNutrientJSON(key, val string) map[string]interface{} {
-    exp := regexp.MustCompile(QTY_REGEX)
-    
-    match := exp.FindIndex([]byte(val))
-
-    if len(match) == 2 {
-        i, j := match[0], match[1]
-        qty, err := strconv.ParseFloat(val[i:j], 64)
-        if err != nil {
-            log.Printf("error: %+v", err)
-        }
-
-        unit, name := GetUnit(key), CreateName(key)
-        return map[string]interface{}{
-            "qty": qty,
-            "unit": unit,
-            "name": name,
+func collectRecipes(json interface{}, recipes *[]map[string]interface{}) {
+    switch json := json.(type) {
+    case map[string]interface{}:
+        if schemaType, exists := json["@type"]; exists && isRecipeSchema(schemaType) {
+            *recipes = append(*recipes, json)
+            return
         }
-    }
-
-    return nil
-}
-
-func NormalizeNutritionData(nutrition interface{}) interface{} {
-    // REQUIRES:    nutrition
-    // MODIFIES:    nutrition
-    // EFFECTS:     Converts [nutrition] into a map, and modifies it's
-    //              keys so that it matches the JSON format of 
-    //              {"val": "", "unit": "", "name": ""}
 
-    if nutrition, ok := nutrition.(map[string]interface{}); ok {
-        delete(nutrition, "@type")
-        delete(nutrition, "@context")
-
-        for key, val := range nutrition {
-            if val == nil {
-                delete(nutrition, key)
+        for _, key := range schemaContainerKeys {
+            if node, exists := json[key]; exists {
+                collectRecipes(node, recipes)
             }
-            
-            if val, ok := val.(string); ok {
-                if json := BuildNutrientJSON(key, val); json != nil {
-                    nutrition[key] = json
-                }
-            }
-        }
-    }
-
-    return nutrition
-}
-
-func NormalizeImageData(img interface{}) interface{} {
-    // REQUIRES:    img 
-    // MODIFIES:    img 
-    // EFFECTS:     Peeks into the [img] interface and looks for a string
-    //              that represents the url to an image of the recipe. Url can be
-    //              in the form of a list, map, or string
-    
-    switch img := img.(type) {
-    case map[string]interface{}:
-        if url, exists := img["url"]; exists {
-            return url
         }
     case []interface{}:
-        i := rand.Intn(len(img))
-        switch imgObj := img[i].(type) {
-        case map[string]interface{}:
-            if link, exists := imgObj["url"]; exists {
-                return link
-            }
-        case string:
-            return imgObj
-        default:
-            log.Printf("[PARSER] image url: encountered type %T\n", imgObj)
+        for _, node := range json {
+            collectRecipes(node, recipes)
         }
+    case []map[string]interface{}:
+        for _, node := range json {
+            collectRecipes(node, recipes)
+        }
+    case string, nil:
+        // a reference like "mainEntity": "https://..."
     default:
-        log.Printf("[PARSER] image normalization: encountered type %T\n", img)
+        log.Printf("[PARSER] recipe error: encountered unexpected type %T\n", json)
     }
-
-    return img 
 }
 
 func NormalizeMainEntity(mainEntity interface{}) interface{} {
@@ -0,0 +1,330 @@
+package parser
+
+import (
+    "context"
+    "io"
+    "log"
+    "math/rand"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gocolly/colly"
+    "github.com/temoto/robotstxt"
+)
+
+// user agent modes
+const (
+    // a random browser user agent from uagents.txt and a Google referer
+    UA_SPOOF = "spoof"
+    // identifies as Crawl.BotUserAgent so sites can tell it's us
+    UA_BOT = "bot"
+)
+
+// most hosts the rate limiter keeps track of before idle ones are dropped
+const MAX_LIMITED_HOSTS = 1024
+
+// CrawlPolicy decides how politely the scraper treats recipe sites
+type CrawlPolicy struct {
+    RespectRobots       bool
+    UserAgentMode       string
+    BotUserAgent        string
+    // concurrent requests to the same host, across every collector
+    DomainParallelism   int
+    // time between the start of two requests to the same host, plus up to
+    // RandomDelay on top
+    DomainDelay         time.Duration
+    RandomDelay         time.Duration
+}
+
+var Crawl = CrawlPolicy{
+    RespectRobots:      false,
+    UserAgentMode:      UA_SPOOF,
+    BotUserAgent:       "logit/1.0 (recipe importer; +https://logit-xyz.netlify.app)",
+    DomainParallelism:  2,
+    DomainDelay:        500 * time.Millisecond,
+    RandomDelay:        1 * time.Second,
+}
+
+func LoadCrawlPolicy() {
+    // REQUIRES:    environment variables loaded
+    // MODIFIES:    Crawl
+    // EFFECTS:     Overrides the crawl policy defaults with CRAWL_ROBOTS,
+    //              CRAWL_USER_AGENT (spoof or bot), CRAWL_BOT_USER_AGENT,
+    //              CRAWL_DOMAIN_PARALLELISM, CRAWL_DOMAIN_DELAY and
+    //              CRAWL_RANDOM_DELAY. Invalid values keep the default
+
+    if val := os.Getenv("CRAWL_ROBOTS"); val != "" {
+        if robots, err := strconv.ParseBool(val); err == nil {
+            Crawl.RespectRobots = robots
+        } else {
+            log.Printf("[PARSER] invalid CRAWL_ROBOTS: %s\n", val)
+        }
+    }
+
+    switch mode := strings.ToLower(os.Getenv("CRAWL_USER_AGENT")); mode {
+    case "":
+    case UA_SPOOF, UA_BOT:
+        Crawl.UserAgentMode = mode
+    default:
+        log.Printf("[PARSER] invalid CRAWL_USER_AGENT: %s\n", mode)
+    }
+
+    if ua := os.Getenv("CRAWL_BOT_USER_AGENT"); ua != "" {
+        Crawl.BotUserAgent = ua
+    }
+
+    if val := os.Getenv("CRAWL_DOMAIN_PARALLELISM"); val != "" {
+        if n, err := strconv.Atoi(val); err == nil && n > 0 {
+            Crawl.DomainParallelism = n
+        } else {
+            log.Printf("[PARSER] invalid CRAWL_DOMAIN_PARALLELISM: %s\n", val)
+        }
+    }
+
+    loadDuration("CRAWL_DOMAIN_DELAY", &Crawl.DomainDelay)
+    loadDuration("CRAWL_RANDOM_DELAY", &Crawl.RandomDelay)
+}
+
+func loadDuration(name string, d *time.Duration) {
+    val := os.Getenv(name)
+    if val == "" {
+        return
+    }
+
+    if parsed, err := time.ParseDuration(val); err == nil && parsed >= 0 {
+        *d = parsed
+    } else {
+        log.Printf("[PARSER] invalid %s: %s\n", name, val)
+    }
+}
+
+func (p CrawlPolicy) userAgent(uagents []string) string {
+    if p.UserAgentMode == UA_BOT {
+        return p.BotUserAgent
+    }
+
+    return uagents[rand.Intn(len(uagents))]
+}
+
+func (p CrawlPolicy) Apply(c *colly.Collector, uagents []string) {
+    // REQUIRES:    c, uagents
+    // MODIFIES:    c
+    // EFFECTS:     Sets the collector's user agent. colly's own robots.txt
+    //              check reads the whole file whatever its size, so it's off
+    //              and robotsHandler checks instead
+
+    c.UserAgent = p.userAgent(uagents)
+    c.IgnoreRobotsTxt = true
+}
+
+// robotsRules keeps the robots.txt of each host for the lifetime of a
+// collector, like colly's own check does
+type robotsRules struct {
+    client  *http.Client
+    hosts   map[string]*robotstxt.RobotsData
+    lock    sync.Mutex
+}
+
+func robotsHandler(c *colly.Collector, transport http.RoundTripper) colly.RequestCallback {
+    // REQUIRES:    c from newCollector, transport of c
+    // MODIFIES:    none
+    // EFFECTS:     Returns a callback that aborts requests robots.txt doesn't
+    //              allow [c]'s user agent to make, with ErrRobotsTxtBlocked as
+    //              the result's error. robots.txt is fetched through
+    //              [transport] and no more than Policy.MaxBodySize of it is
+    //              read. Does nothing unless Crawl.RespectRobots
+
+    rules := &robotsRules{
+        client: &http.Client{Transport: transport, CheckRedirect: Policy.redirectHandler},
+        hosts:  map[string]*robotstxt.RobotsData{},
+    }
+
+    return func(r *colly.Request) {
+        if !Crawl.RespectRobots {
+            return
+        }
+
+        robots, err := rules.get(r.URL, c.UserAgent)
+        if err == nil && !robots.TestAgent(r.URL.EscapedPath(), c.UserAgent) {
+            err = colly.ErrRobotsTxtBlocked
+        }
+        if err != nil {
+            resultOf(r).err = err
+            r.Abort()
+        }
+    }
+}
+
+func (rules *robotsRules) get(u *url.URL, userAgent string) (*robotstxt.RobotsData, error) {
+    rules.lock.Lock()
+    robots, exists := rules.hosts[u.Host]
+    rules.lock.Unlock()
+    if exists {
+        return robots, nil
+    }
+
+    req, err := http.NewRequest("GET", u.Scheme+"://"+u.Host+"/robots.txt", nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("User-Agent", userAgent)
+
+    resp, err := rules.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    // cut off like any other body, the rules past the limit are ignored
+    body, err := io.ReadAll(io.LimitReader(resp.Body, int64(Policy.MaxBodySize)))
+    if err != nil {
+        return nil, err
+    }
+
+    robots, err = robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+    if err != nil {
+        return nil, err
+    }
+
+    rules.lock.Lock()
+    rules.hosts[u.Host] = robots
+    rules.lock.Unlock()
+
+    return robots, nil
+}
+
+func (p CrawlPolicy) transport(next http.RoundTripper, timeout time.Duration) http.RoundTripper {
+    return &limitedTransport{next: next, timeout: timeout}
+}
+
+// limitedTransport waits for the host's turn before every request, including
+// redirects and robots.txt. Collectors come and go with each scrape, so the
+// limits are kept in domainLimits instead of colly's per collector rules.
+// The timeout starts once it's the request's turn, a client timeout would
+// also count the time spent waiting
+type limitedTransport struct {
+    next    http.RoundTripper
+    timeout time.Duration
+}
+
+type domainSlot struct {
+    running chan struct{}
+    next    time.Time
+    lock    sync.Mutex
+}
+
+type domainLimiter struct {
+    slots   map[string]*domainSlot
+    lock    sync.Mutex
+}
+
+var domainLimits = &domainLimiter{
+    slots: map[string]*domainSlot{},
+}
+
+func (l *domainLimiter) slot(host string) *domainSlot {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+
+    if s, exists := l.slots[host]; exists {
+        return s
+    }
+
+    if len(l.slots) >= MAX_LIMITED_HOSTS {
+        now := time.Now()
+        for h, s := range l.slots {
+            s.lock.Lock()
+            idle := len(s.running) == 0 && now.After(s.next)
+            s.lock.Unlock()
+            if idle {
+                delete(l.slots, h)
+            }
+        }
+    }
+
+    s := &domainSlot{
+        running: make(chan struct{}, Crawl.DomainParallelism),
+    }
+    l.slots[host] = s
+
+    return s
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    s := domainLimits.slot(strings.ToLower(req.URL.Hostname()))
+
+    select {
+    case s.running <- struct{}{}:
+    case <-req.Context().Done():
+        return nil, req.Context().Err()
+    }
+    release := func() { <-s.running }
+
+    // reserve the next start time before waiting, so requests queued up
+    // behind each other are spaced out instead of all starting together
+    s.lock.Lock()
+    now := time.Now()
+    start := s.next
+    if start.Before(now) {
+        start = now
+    }
+    gap := Crawl.DomainDelay
+    if Crawl.RandomDelay > 0 {
+        gap += time.Duration(rand.Int63n(int64(Crawl.RandomDelay)))
+    }
+    s.next = start.Add(gap)
+    s.lock.Unlock()
+
+    if wait := time.Until(start); wait > 0 {
+        timer := time.NewTimer(wait)
+        select {
+        case <-timer.C:
+        case <-req.Context().Done():
+            timer.Stop()
+            release()
+            return nil, req.Context().Err()
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+    resp, err := t.next.RoundTrip(req.WithContext(ctx))
+    if err != nil {
+        cancel()
+        release()
+        return nil, err
+    }
+
+    // the request is running until its body is read, reaching the end
+    // counts as well as closing it
+    resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() {
+        cancel()
+        release()
+    }}
+    return resp, nil
+}
+
+type releaseBody struct {
+    io.ReadCloser
+    release func()
+    once    sync.Once
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+    n, err := b.ReadCloser.Read(p)
+    if err == io.EOF {
+        b.once.Do(b.release)
+    }
+
+    return n, err
+}
+
+func (b *releaseBody) Close() error {
+    err := b.ReadCloser.Close()
+    b.once.Do(b.release)
+    return err
+}
@@ -0,0 +1,226 @@
+package parser
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// runs [test] with a crawl policy and limiter of its own, the limiter keeps
+// each host's parallelism from when it first saw the host
+func withCrawl(t *testing.T, crawl CrawlPolicy, test func()) {
+    saved, limits := Crawl, domainLimits
+    Policy.AllowPrivate = true
+    Crawl = crawl
+    domainLimits = &domainLimiter{slots: map[string]*domainSlot{}}
+    defer func() {
+        Policy.AllowPrivate = false
+        Crawl, domainLimits = saved, limits
+    }()
+
+    test()
+}
+
+func TestLoadCrawlPolicy(t *testing.T) {
+    saved := Crawl
+    defer func() { Crawl = saved }()
+
+    t.Setenv("CRAWL_ROBOTS", "true")
+    t.Setenv("CRAWL_USER_AGENT", "BOT")
+    t.Setenv("CRAWL_BOT_USER_AGENT", "test-bot/1.0")
+    t.Setenv("CRAWL_DOMAIN_PARALLELISM", "3")
+    t.Setenv("CRAWL_DOMAIN_DELAY", "250ms")
+    t.Setenv("CRAWL_RANDOM_DELAY", "2s")
+    LoadCrawlPolicy()
+
+    want := CrawlPolicy{
+        RespectRobots:      true,
+        UserAgentMode:      UA_BOT,
+        BotUserAgent:       "test-bot/1.0",
+        DomainParallelism:  3,
+        DomainDelay:        250 * time.Millisecond,
+        RandomDelay:        2 * time.Second,
+    }
+    if Crawl != want {
+        t.Errorf("Crawl = %+v, want %+v", Crawl, want)
+    }
+
+    // invalid values keep what was there
+    t.Setenv("CRAWL_ROBOTS", "sometimes")
+    t.Setenv("CRAWL_USER_AGENT", "browser")
+    t.Setenv("CRAWL_DOMAIN_PARALLELISM", "0")
+    t.Setenv("CRAWL_DOMAIN_DELAY", "-1s")
+    t.Setenv("CRAWL_RANDOM_DELAY", "soon")
+    LoadCrawlPolicy()
+
+    if Crawl != want {
+        t.Errorf("invalid values changed Crawl to %+v, want %+v", Crawl, want)
+    }
+}
+
+func TestRobotsDisallowed(t *testing.T) {
+    var visited int32
+    mux := http.NewServeMux()
+    mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, _ *http.Request) {
+        fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+    })
+    mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+        if strings.HasPrefix(r.URL.Path, "/private/") {
+            atomic.StoreInt32(&visited, 1)
+        }
+        http.ServeFile(w, r, FIXTURE_DIR+"/microdata.html")
+    })
+    server := httptest.NewServer(mux)
+    defer server.Close()
+
+    crawl := Crawl
+    crawl.RespectRobots = true
+    crawl.UserAgentMode = UA_BOT
+    crawl.DomainDelay, crawl.RandomDelay = 0, 0
+
+    withCrawl(t, crawl, func() {
+        Cache = NewMemoryCache()
+
+        _, err := FetchRecipe(server.URL+"/private/recipe", []string{"logit-tests"})
+        var scrapeErr *ScrapeError
+        if !errors.As(err, &scrapeErr) || scrapeErr.Code != BLOCKED_BY_SITE {
+            t.Fatalf("error = %+v, want %s", err, BLOCKED_BY_SITE)
+        }
+        if atomic.LoadInt32(&visited) != 0 {
+            t.Errorf("the disallowed page was requested")
+        }
+
+        // allowed paths of the same site still work
+        if _, err := FetchRecipe(server.URL+"/recipe", []string{"logit-tests"}); err != nil {
+            t.Errorf("allowed path failed: %+v", err)
+        }
+    })
+}
+
+func TestRobotsBodyLimited(t *testing.T) {
+    const ROBOTS_SIZE = 64 * 1024 * 1024
+
+    var hungUp int32
+    mux := http.NewServeMux()
+    mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, _ *http.Request) {
+        fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+        line := []byte("# " + strings.Repeat("x", 1021) + "\n")
+        for written := 0; written < ROBOTS_SIZE; written += len(line) {
+            if _, err := w.Write(line); err != nil {
+                atomic.StoreInt32(&hungUp, 1)
+                return
+            }
+        }
+    })
+    mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+        http.ServeFile(w, r, FIXTURE_DIR+"/microdata.html")
+    })
+    server := httptest.NewServer(mux)
+    defer server.Close()
+
+    crawl := Crawl
+    crawl.RespectRobots = true
+    crawl.UserAgentMode = UA_BOT
+    crawl.DomainDelay, crawl.RandomDelay = 0, 0
+
+    maxBodySize := Policy.MaxBodySize
+    Policy.MaxBodySize = 64 * 1024
+    defer func() { Policy.MaxBodySize = maxBodySize }()
+
+    withCrawl(t, crawl, func() {
+        Cache = NewMemoryCache()
+
+        // the rules before the limit still count
+        _, err := FetchRecipe(server.URL+"/private/recipe", []string{"logit-tests"})
+        var scrapeErr *ScrapeError
+        if !errors.As(err, &scrapeErr) || scrapeErr.Code != BLOCKED_BY_SITE {
+            t.Fatalf("error = %+v, want %s", err, BLOCKED_BY_SITE)
+        }
+    })
+
+    // the server notices once the client stops reading, long before the
+    // whole file is sent
+    server.CloseClientConnections()
+    time.Sleep(50 * time.Millisecond)
+    if atomic.LoadInt32(&hungUp) == 0 {
+        t.Errorf("the whole robots.txt was read")
+    }
+}
+
+func TestSameHostSerialized(t *testing.T) {
+    const REQUESTS = 6
+    const DELAY = 20 * time.Millisecond
+
+    var running, maxRunning int32
+    var lock sync.Mutex
+    var starts []time.Time
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+        n := atomic.AddInt32(&running, 1)
+        defer atomic.AddInt32(&running, -1)
+        for {
+            max := atomic.LoadInt32(&maxRunning)
+            if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+                break
+            }
+        }
+
+        lock.Lock()
+        starts = append(starts, time.Now())
+        lock.Unlock()
+
+        // long enough for the others to pile up if they weren't waiting
+        time.Sleep(10 * time.Millisecond)
+        fmt.Fprint(w, "ok")
+    }))
+    defer server.Close()
+
+    crawl := Crawl
+    crawl.DomainParallelism = 1
+    crawl.DomainDelay, crawl.RandomDelay = DELAY, 0
+
+    withCrawl(t, crawl, func() {
+        client := &http.Client{Transport: Crawl.transport(Policy.transport(), Policy.Timeout)}
+
+        var wg sync.WaitGroup
+        errs := make(chan error, REQUESTS)
+        for i := 0; i < REQUESTS; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                response, err := client.Get(server.URL)
+                if err != nil {
+                    errs <- err
+                    return
+                }
+                io.Copy(io.Discard, response.Body)
+                response.Body.Close()
+            }()
+        }
+        wg.Wait()
+        close(errs)
+
+        for err := range errs {
+            t.Fatalf("unexpected error: %+v", err)
+        }
+    })
+
+    if maxRunning != 1 {
+        t.Errorf("%d requests ran at once, want 1", maxRunning)
+    }
+    if len(starts) != REQUESTS {
+        t.Fatalf("server saw %d requests, want %d", len(starts), REQUESTS)
+    }
+    for i := 1; i < len(starts); i++ {
+        // the delay is between the starts, give the clock a little room
+        if gap := starts[i].Sub(starts[i-1]); gap < DELAY-2*time.Millisecond {
+            t.Errorf("request %d started %v after the previous one, want at least %v", i, gap, DELAY)
+        }
+    }
+}
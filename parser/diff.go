@@ -0,0 +1,80 @@
+package parser
+
+import (
+    "math"
+    "strings"
+
+    "logit/models"
+)
+
+// nutrient values closer than this are rounding, not a change
+const NUTRIENT_TOLERANCE = 0.01
+
+func DiffRecipes(before, after models.Recipe) models.RecipeDiff {
+    // REQUIRES:    before, after normalized with NormalizeRecipe
+    // MODIFIES:    none
+    // EFFECTS:     Returns the ingredients only one of the recipes has, ignoring
+    //              order, case and spacing, and the servings and per serving
+    //              nutrients that differ
+
+    diff := models.RecipeDiff{
+        AddedIngredients:   ingredientsMissingFrom(before.Ingredients, after.Ingredients),
+        RemovedIngredients: ingredientsMissingFrom(after.Ingredients, before.Ingredients),
+        Nutrition:          map[string]models.ValueChange{},
+    }
+
+    if math.Abs(before.Servings-after.Servings) > NUTRIENT_TOLERANCE {
+        diff.Servings = &models.ValueChange{Old: before.Servings, New: after.Servings}
+    }
+
+    beforeNutrients, afterNutrients := servingNutrients(before), servingNutrients(after)
+    for key, nutrient := range afterNutrients {
+        if math.Abs(beforeNutrients[key]-nutrient) > NUTRIENT_TOLERANCE {
+            diff.Nutrition[key] = models.ValueChange{Old: beforeNutrients[key], New: nutrient}
+        }
+    }
+    for key, nutrient := range beforeNutrients {
+        if _, exists := afterNutrients[key]; !exists && math.Abs(nutrient) > NUTRIENT_TOLERANCE {
+            diff.Nutrition[key] = models.ValueChange{Old: nutrient, New: 0}
+        }
+    }
+
+    return diff
+}
+
+func ingredientsMissingFrom(list, from []string) []string {
+    // the ingredients of [from] that aren't in [list], in the order of [from]
+    counts := map[string]int{}
+    for _, ingredient := range list {
+        counts[ingredientKey(ingredient)]++
+    }
+
+    var missing []string
+    for _, ingredient := range from {
+        key := ingredientKey(ingredient)
+        if counts[key] > 0 {
+            counts[key]--
+            continue
+        }
+        missing = append(missing, ingredient)
+    }
+
+    return missing
+}
+
+func ingredientKey(ingredient string) string {
+    return strings.ToLower(SanitizeText(ingredient))
+}
+
+func servingNutrients(recipe models.Recipe) map[string]float64 {
+    nutrients := map[string]float64{}
+    if recipe.Nutrition == nil {
+        return nutrients
+    }
+
+    for key, nutrient := range recipe.Nutrition.Nutrients {
+        nutrients[key] = nutrient.Qty
+    }
+
+    return nutrients
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+    "reflect"
+    "testing"
+
+    "logit/models"
+)
+
+func diffNutrition(values map[string]float64) *models.NutritionInfo {
+    info := &models.NutritionInfo{Servings: 1, Nutrients: map[string]models.Nutrient{}}
+    for key, qty := range values {
+        info.Nutrients[key] = models.Nutrient{Qty: qty}
+    }
+
+    return info
+}
+
+func TestDiffRecipes(t *testing.T) {
+    base := models.Recipe{
+        Servings:       4,
+        Ingredients:    []string{"2 cups flour", "1 egg", "1 tsp salt"},
+        Nutrition:      diffNutrition(map[string]float64{"calories": 250, "sodiumContent": 300}),
+    }
+
+    tests := []struct {
+        name    string
+        // base is the recipe before unless [before] changes it
+        before  func(*models.Recipe)
+        change  func(*models.Recipe)
+        want    models.RecipeDiff
+    }{
+        {
+            name:   "unchanged",
+            change: func(*models.Recipe) {},
+        },
+        {
+            name: "reordered ingredients",
+            change: func(r *models.Recipe) {
+                r.Ingredients = []string{"1 tsp salt", "2 cups flour", "1 egg"}
+            },
+        },
+        {
+            name: "re-cased and re-spaced ingredients",
+            change: func(r *models.Recipe) {
+                r.Ingredients = []string{"2 Cups  Flour", "1 EGG", "1 tsp salt"}
+            },
+        },
+        {
+            name: "changed ingredient",
+            change: func(r *models.Recipe) {
+                r.Ingredients = []string{"3 cups flour", "1 egg", "1 tsp salt"}
+            },
+            want: models.RecipeDiff{
+                AddedIngredients:   []string{"3 cups flour"},
+                RemovedIngredients: []string{"2 cups flour"},
+            },
+        },
+        {
+            name: "duplicate line added",
+            change: func(r *models.Recipe) {
+                r.Ingredients = []string{"2 cups flour", "1 egg", "1 tsp salt", "1 egg"}
+            },
+            want: models.RecipeDiff{AddedIngredients: []string{"1 egg"}},
+        },
+        {
+            name: "duplicate line removed",
+            before: func(r *models.Recipe) {
+                r.Ingredients = []string{"2 cups flour", "1 egg", "1 tsp salt", "1 Egg"}
+            },
+            change: func(*models.Recipe) {},
+            want: models.RecipeDiff{RemovedIngredients: []string{"1 Egg"}},
+        },
+        {
+            name: "removed nutrient",
+            change: func(r *models.Recipe) {
+                r.Nutrition = diffNutrition(map[string]float64{"calories": 250})
+            },
+            want: models.RecipeDiff{
+                Nutrition: map[string]models.ValueChange{"sodiumContent": {Old: 300, New: 0}},
+            },
+        },
+        {
+            name: "removed nutrition",
+            change: func(r *models.Recipe) {
+                r.Nutrition = nil
+            },
+            want: models.RecipeDiff{
+                Nutrition: map[string]models.ValueChange{
+                    "calories":         {Old: 250, New: 0},
+                    "sodiumContent":    {Old: 300, New: 0},
+                },
+            },
+        },
+        {
+            name: "added and changed nutrients",
+            change: func(r *models.Recipe) {
+                r.Nutrition = diffNutrition(map[string]float64{"calories": 260, "sodiumContent": 300, "fiberContent": 2})
+            },
+            want: models.RecipeDiff{
+                Nutrition: map[string]models.ValueChange{
+                    "calories":     {Old: 250, New: 260},
+                    "fiberContent": {Old: 0, New: 2},
+                },
+            },
+        },
+        {
+            name: "rounding isn't a change",
+            change: func(r *models.Recipe) {
+                r.Servings = 4.001
+                r.Nutrition = diffNutrition(map[string]float64{"calories": 250.004, "sodiumContent": 300})
+            },
+        },
+        {
+            name: "servings changed",
+            change: func(r *models.Recipe) {
+                r.Servings = 6
+            },
+            want: models.RecipeDiff{Servings: &models.ValueChange{Old: 4, New: 6}},
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            before, after := base, base
+            if test.before != nil {
+                test.before(&before)
+            }
+            test.change(&after)
+
+            diff := DiffRecipes(before, after)
+            if test.want.Nutrition == nil {
+                test.want.Nutrition = map[string]models.ValueChange{}
+            }
+            if !reflect.DeepEqual(diff, test.want) {
+                t.Errorf("DiffRecipes = %+v, want %+v", diff, test.want)
+            }
+            if diff.IsEmpty() != reflect.DeepEqual(test.want, models.RecipeDiff{Nutrition: map[string]models.ValueChange{}}) {
+                t.Errorf("IsEmpty = %v for %+v", diff.IsEmpty(), diff)
+            }
+        })
+    }
+}
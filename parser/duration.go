@@ -0,0 +1,203 @@
+package parser
+
+import (
+    "fmt"
+    "log"
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "logit/models"
+)
+
+// ISO 8601 durations, e.g. PT1H15M, PT90M, P0DT0H45M or PT0.5H. Sites
+// lower case the designators, "PT1h", but the P has to be upper case, "p"
+// isn't a duration
+var isoDurationExp = regexp.MustCompile(
+    `^P(?i:(?:(\d+(?:[.,]\d+)?)W)?(?:(\d+(?:[.,]\d+)?)D)?` +
+    `(?:T(?:(\d+(?:[.,]\d+)?)H)?(?:(\d+(?:[.,]\d+)?)M)?(?:(\d+(?:[.,]\d+)?)S)?)?)$`,
+)
+
+// numbers of free text durations, "1 1/2", "1/2", "1.5" or "1,5". Read with
+// parseNumber like the amounts of ingredients
+const durationNumberPattern = `\d+\s+\d+/\d+|\d+/\d+|\d+(?:[.,]\d+)?`
+
+// a part of a free text duration, "45 minutes", "1 1/2 hours" or a range,
+// "20-25 mins", which counts as its upper end
+const durationPartPattern = `(` + durationNumberPattern + `)(?:\s*(?:-|–|to)\s*(` + durationNumberPattern + `))?\s*([a-z]+)\.?`
+
+var (
+    textDurationPartExp = regexp.MustCompile(`(?i)` + durationPartPattern)
+    // e.g. "about 45 minutes", "1 hr 15 mins", "1 hour and 30 minutes" or
+    // "1h30m". Anything else in the text makes it not a duration
+    textDurationExp = regexp.MustCompile(`(?i)^(?:(?:about|approx\.?|approximately|~)\s*)?` +
+        durationPartPattern + `(?:\s*(?:,|\+|and)?\s*` + durationPartPattern + `)*$`)
+    bareDurationExp = regexp.MustCompile(`^\d+(?:[.,]\d+)?$`)
+)
+
+// words accepted as units in free text durations
+var textDurationUnits = map[string]bool{
+    "day": true, "days": true, "d": true,
+    "hour": true, "hours": true, "hr": true, "hrs": true, "h": true,
+    "minute": true, "minutes": true, "min": true, "mins": true, "m": true,
+    "second": true, "seconds": true, "sec": true, "secs": true, "s": true,
+}
+
+// minutes in each unit of a duration
+var durationUnits = map[string]float64{
+    "w": 7 * 24 * 60,
+    "d": 24 * 60,
+    "h": 60,
+    "m": 1,
+    "s": 1.0 / 60,
+}
+
+func ParseDuration(raw string) (int, error) {
+    // REQUIRES:    raw
+    // MODIFIES:    none
+    // EFFECTS:     Converts a duration into whole minutes. Understands ISO 8601
+    //              (the schema.org format), including the non-standard forms
+    //              sites emit, and falls back to free text like "45 minutes"
+    //              or "1 1/2 hours". Text with anything but the duration in it
+    //              is an error, so is a sign, "-PT5M"
+
+    raw = strings.TrimSpace(raw)
+    if raw == "" {
+        return 0, fmt.Errorf("empty duration")
+    }
+
+    if match := isoDurationExp.FindStringSubmatch(raw); match != nil && raw != "P" && !strings.EqualFold(raw, "PT") {
+        var minutes float64
+        for i, unit := range []string{"w", "d", "h", "m", "s"} {
+            if match[i+1] != "" {
+                minutes += parseDurationValue(match[i+1]) * durationUnits[unit]
+            }
+        }
+
+        return int(math.Round(minutes)), nil
+    }
+
+    // a bare number is almost always minutes
+    if bareDurationExp.MatchString(raw) {
+        return int(math.Round(parseDurationValue(raw))), nil
+    }
+
+    if !textDurationExp.MatchString(raw) {
+        return 0, fmt.Errorf("couldn't parse duration %q", raw)
+    }
+
+    var minutes float64
+    for _, match := range textDurationPartExp.FindAllStringSubmatch(raw, -1) {
+        unit := strings.ToLower(match[3])
+        if !textDurationUnits[unit] {
+            return 0, fmt.Errorf("couldn't parse duration %q", raw)
+        }
+
+        number := match[1]
+        if match[2] != "" {
+            number = match[2]
+        }
+        value, ok := parseNumber(number)
+        if !ok && parseDurationValue(number) != 0 {
+            return 0, fmt.Errorf("couldn't parse duration %q", raw)
+        }
+
+        minutes += value * durationUnits[unit[:1]]
+    }
+
+    return int(math.Round(minutes)), nil
+}
+
+func parseDurationValue(val string) float64 {
+    f, _ := strconv.ParseFloat(strings.Replace(val, ",", ".", 1), 64)
+    return f
+}
+
+func FormatDuration(minutes int) string {
+    // REQUIRES:    minutes >= 0
+    // MODIFIES:    none
+    // EFFECTS:     Formats minutes as "1 day 2 hrs 5 mins"
+
+    if minutes == 0 {
+        return "0 mins"
+    }
+
+    days, hours, mins := minutes/(24*60), (minutes/60)%24, minutes%60
+
+    var parts []string
+    if days > 0 {
+        parts = append(parts, pluralize(days, "day", "days"))
+    }
+    if hours > 0 {
+        parts = append(parts, pluralize(hours, "hr", "hrs"))
+    }
+    if mins > 0 {
+        parts = append(parts, pluralize(mins, "min", "mins"))
+    }
+
+    return strings.Join(parts, " ")
+}
+
+func pluralize(n int, singular, plural string) string {
+    if n == 1 {
+        return fmt.Sprintf("%d %s", n, singular)
+    }
+
+    return fmt.Sprintf("%d %s", n, plural)
+}
+
+func NormalizeDuration(raw string) (models.Duration, error) {
+    duration := models.Duration{Raw: raw}
+    if raw == "" {
+        return duration, nil
+    }
+
+    minutes, err := ParseDuration(raw)
+    if err != nil {
+        return duration, err
+    }
+
+    duration.Minutes = minutes
+    duration.Text = FormatDuration(minutes)
+    duration.Valid = true
+    return duration, nil
+}
+
+func NormalizeTimes(prepTime, cookTime, totalTime string) models.RecipeTimes {
+    // REQUIRES:    prepTime, cookTime, totalTime
+    // MODIFIES:    none
+    // EFFECTS:     Converts the recipe times into minutes. When the total is
+    //              missing it is computed from the prep and cook times. Values
+    //              that can't be parsed are reported in Errors
+
+    var times models.RecipeTimes
+    var err error
+
+    fields := []struct {
+        key         string
+        raw         string
+        duration    *models.Duration
+    }{
+        {"prepTime", prepTime, &times.Prep},
+        {"cookTime", cookTime, &times.Cook},
+        {"totalTime", totalTime, &times.Total},
+    }
+
+    for _, field := range fields {
+        if *field.duration, err = NormalizeDuration(field.raw); err != nil {
+            log.Printf("[PARSER] duration normalization: %+v\n", err)
+            times.Errors = append(times.Errors, fmt.Sprintf("%s: %v", field.key, err))
+        }
+    }
+
+    if !times.Total.Valid && (times.Prep.Valid || times.Cook.Valid) {
+        minutes := times.Prep.Minutes + times.Cook.Minutes
+        times.Total.Minutes = minutes
+        times.Total.Text = FormatDuration(minutes)
+        times.Total.Valid = true
+        times.Total.Computed = true
+    }
+
+    return times
+}
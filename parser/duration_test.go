@@ -0,0 +1,74 @@
+package parser
+
+import (
+    "testing"
+)
+
+func TestParseDuration(t *testing.T) {
+    tests := []struct {
+        raw     string
+        minutes int
+        valid   bool
+    }{
+        {"PT1H15M", 75, true},
+        {"PT90M", 90, true},
+        {"P0DT0H45M", 45, true},
+        {"PT0.5H", 30, true},
+        {"PT1h", 60, true},
+        {"P1D", 1440, true},
+        {"45", 45, true},
+        {"45 minutes", 45, true},
+        {"1 hr 15 mins", 75, true},
+        {"1 hour and 30 minutes", 90, true},
+        {"1h30m", 90, true},
+        {"about 20 min.", 20, true},
+        {"20-25 minutes", 25, true},
+        {"1,5 hours", 90, true},
+        // fractions and mixed numbers, "1½" is sanitized into "1 1/2"
+        {"1/2 hour", 30, true},
+        {"1 1/2 hours", 90, true},
+        {"1½ hours", 90, true},
+        {"3/4 hr", 45, true},
+        {"1 hour 1/2", 0, false},
+        {"0 minutes", 0, true},
+        {"", 0, false},
+        {"P", 0, false},
+        {"PT", 0, false},
+        {"-PT5M", 0, false},
+        {"-5", 0, false},
+        {"-5 minutes", 0, false},
+        {"+5", 0, false},
+        {"pt5m", 0, false},
+        {"5 parsecs", 0, false},
+        {"overnight", 0, false},
+        {"bake for 20 minutes", 0, false},
+        {"20 minutes, plus chilling", 0, false},
+    }
+
+    for _, test := range tests {
+        minutes, err := ParseDuration(SanitizeText(test.raw))
+        if valid := err == nil; valid != test.valid || minutes != test.minutes {
+            t.Errorf("ParseDuration(%q) = %d, %v, want %d, valid %v", test.raw, minutes, err, test.minutes, test.valid)
+        }
+    }
+}
+
+func TestNormalizeTimesFractions(t *testing.T) {
+    recipe := NormalizeRecipe(map[string]interface{}{"prepTime": "1/2 hour", "cookTime": "1½ hours"})
+    times := recipe.Times
+
+    if times.Prep.Minutes != 30 || times.Cook.Minutes != 90 {
+        t.Errorf("prep, cook = %d, %d, want 30, 90", times.Prep.Minutes, times.Cook.Minutes)
+    }
+    if !times.Total.Computed || times.Total.Minutes != 120 {
+        t.Errorf("total = %+v, want a computed 120", times.Total)
+    }
+
+    times = NormalizeTimes("-PT5M", "PT10M", "")
+    if times.Prep.Valid || len(times.Errors) != 1 {
+        t.Errorf("prep = %+v, errors %v, want it invalid", times.Prep, times.Errors)
+    }
+    if times.Total.Minutes != 10 {
+        t.Errorf("total = %d, want 10", times.Total.Minutes)
+    }
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/gocolly/colly"
+
+    "logit/models"
+)
+
+// error codes returned to the client in models.Response.Code
+const (
+    INVALID_URL         = "invalid_url"
+    FETCH_FAILED        = "fetch_failed"
+    BLOCKED_BY_SITE     = "blocked_by_site"
+    NO_RECIPE_FOUND     = "no_recipe_found"
+    MALFORMED_JSON_LD   = "malformed_json_ld"
+    PARTIAL_RECIPE      = "partial_recipe"
+    RECIPE_NOT_FOUND    = "recipe_not_found"
+)
+
+// ScrapeError explains why a link didn't produce a (complete) recipe and
+// which HTTP status the client should get for it
+type ScrapeError struct {
+    Code    string
+    Status  int
+    Message string
+    Err     error
+}
+
+func (e *ScrapeError) Error() string {
+    if e.Err != nil {
+        return fmt.Sprintf("%s: %v", e.Message, e.Err)
+    }
+
+    return e.Message
+}
+
+func (e *ScrapeError) Unwrap() error {
+    return e.Err
+}
+
+func invalidURLError(err error) *ScrapeError {
+    return &ScrapeError{
+        Code:       INVALID_URL,
+        Status:     http.StatusBadRequest,
+        Message:    "this link can't be used to find a recipe",
+        Err:        err,
+    }
+}
+
+func fetchError(err error, status int) *ScrapeError {
+    // REQUIRES:    err
+    // MODIFIES:    none
+    // EFFECTS:     Classifies a failed visit. Redirects to a forbidden address
+    //              are invalid links, robots.txt, refusals and rate limiting
+    //              from the site mean we were blocked, everything else is a
+    //              failed fetch
+
+    var blockedErr *BlockedURLError
+    if errors.As(err, &blockedErr) {
+        return invalidURLError(err)
+    }
+
+    if errors.Is(err, colly.ErrRobotsTxtBlocked) {
+        return &ScrapeError{
+            Code:       BLOCKED_BY_SITE,
+            Status:     http.StatusBadGateway,
+            Message:    "the recipe site doesn't allow crawlers on this page",
+            Err:        err,
+        }
+    }
+
+    switch status {
+    case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+        return &ScrapeError{
+            Code:       BLOCKED_BY_SITE,
+            Status:     http.StatusBadGateway,
+            Message:    "the recipe site refused our request",
+            Err:        err,
+        }
+    }
+
+    return &ScrapeError{
+        Code:       FETCH_FAILED,
+        Status:     http.StatusBadGateway,
+        Message:    "couldn't load the recipe page",
+        Err:        err,
+    }
+}
+
+func noRecipeError(malformed bool) *ScrapeError {
+    if malformed {
+        return &ScrapeError{
+            Code:       MALFORMED_JSON_LD,
+            Status:     http.StatusUnprocessableEntity,
+            Message:    "the page's recipe data is broken, try the recipe builder instead",
+        }
+    }
+
+    return &ScrapeError{
+        Code:       NO_RECIPE_FOUND,
+        Status:     http.StatusUnprocessableEntity,
+        Message:    "couldn't find a recipe on this page, try the recipe builder instead",
+    }
+}
+
+func recipeNotFoundError(count int) *ScrapeError {
+    return &ScrapeError{
+        Code:       RECIPE_NOT_FOUND,
+        Status:     http.StatusNotFound,
+        Message:    fmt.Sprintf("the page has %d recipes, none of them is the one asked for", count),
+    }
+}
+
+func partialRecipeError(recipe models.Recipe) error {
+    // REQUIRES:    recipe
+    // MODIFIES:    none
+    // EFFECTS:     Returns a *ScrapeError listing what the recipe is missing,
+    //              or nil if it has everything the app needs. The return type
+    //              is error so a complete recipe doesn't come back with a
+    //              non-nil error holding a nil pointer
+
+    var missing []string
+    if recipe.Name == "" {
+        missing = append(missing, "name")
+    }
+    if len(recipe.Ingredients) == 0 {
+        missing = append(missing, "ingredients")
+    }
+    if recipe.Nutrition == nil || len(recipe.Nutrition.Nutrients) == 0 {
+        missing = append(missing, "nutrition")
+    }
+
+    if len(missing) == 0 {
+        return nil
+    }
+
+    return &ScrapeError{
+        Code:       PARTIAL_RECIPE,
+        Status:     http.StatusOK,
+        Message:    "recipe is missing " + strings.Join(missing, ", "),
+    }
+}
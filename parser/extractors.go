@@ -0,0 +1,132 @@
+package parser
+
+import (
+    "regexp"
+    "strings"
+
+    "github.com/PuerkitoBio/goquery"
+)
+
+// nutrition label suffixes used by WP Recipe Maker and their schema.org keys
+var wprmNutrients = map[string]string{
+    "calories":         "calories",
+    "carbohydrates":    "carbohydrateContent",
+    "protein":          "proteinContent",
+    "fat":              "fatContent",
+    "saturated_fat":    "saturatedFatContent",
+    "trans_fat":        "transFatContent",
+    "cholesterol":      "cholesterolContent",
+    "sodium":           "sodiumContent",
+    "fiber":            "fiberContent",
+    "sugar":            "sugarContent",
+    "serving_size":     "servingSize",
+}
+
+// wprmExtractor fills in what a WP Recipe Maker site left out of its
+// ld+json from the plugin's own recipe card
+type wprmExtractor struct{}
+
+func (wprmExtractor) Extract(page *goquery.Selection, raw map[string]interface{}) map[string]interface{} {
+    // REQUIRES:    page
+    // MODIFIES:    raw
+    // EFFECTS:     Reads the nutrition from the card's nutrition label when the
+    //              schema has none, and rebuilds the ingredients from the card
+    //              when the schema splits them differently, e.g. one entry for
+    //              the amount and one for the name
+
+    card := page.Find(".wprm-recipe-container").First()
+    if card.Length() == 0 {
+        return raw
+    }
+
+    if raw == nil {
+        raw = map[string]interface{}{
+            "@type": "Recipe",
+            "name":  strings.TrimSpace(card.Find(".wprm-recipe-name").First().Text()),
+        }
+    }
+
+    if _, exists := raw["nutrition"]; !exists {
+        nutrition := map[string]interface{}{}
+        card.Find(".wprm-nutrition-label-text-nutrition-container").Each(func(_ int, s *goquery.Selection) {
+            for class, key := range wprmNutrients {
+                if s.HasClass("wprm-nutrition-label-text-nutrition-container-" + class) {
+                    value := strings.TrimSpace(s.Find(".wprm-nutrition-label-text-nutrition-value").Text())
+                    unit := strings.TrimSpace(s.Find(".wprm-nutrition-label-text-nutrition-unit").Text())
+                    nutrition[key] = strings.TrimSpace(value + " " + unit)
+                }
+            }
+        })
+
+        if len(nutrition) > 0 {
+            nutrition["@type"] = "NutritionInformation"
+            raw["nutrition"] = nutrition
+        }
+    }
+
+    var ingredients []interface{}
+    card.Find("li.wprm-recipe-ingredient").Each(func(_ int, li *goquery.Selection) {
+        var parts []string
+        for _, class := range []string{"amount", "unit", "name"} {
+            if text := listItemText(li.Find(".wprm-recipe-ingredient-" + class)); text != "" {
+                parts = append(parts, text)
+            }
+        }
+
+        if len(parts) == 0 {
+            return
+        }
+
+        ingredient := strings.Join(parts, " ")
+        if notes := listItemText(li.Find(".wprm-recipe-ingredient-notes")); notes != "" {
+            ingredient += ", " + notes
+        }
+        ingredients = append(ingredients, ingredient)
+    })
+
+    if len(ingredients) > 0 && len(toStringList(raw["recipeIngredient"], false)) != len(ingredients) {
+        raw["recipeIngredient"] = ingredients
+    }
+
+    return raw
+}
+
+// "For the sauce:", "FROSTING"
+var ingredientHeadingExps = []*regexp.Regexp{
+    regexp.MustCompile(`^[^\d]*:\s*$`),
+    regexp.MustCompile(`^[^a-z\d]+$`),
+}
+
+func isIngredientHeading(line string) bool {
+    for _, exp := range ingredientHeadingExps {
+        if exp.MatchString(line) {
+            return true
+        }
+    }
+
+    return false
+}
+
+// ingredientHeadingsExtractor drops the group headings a site lists among
+// its ingredients, which would otherwise be parsed as ingredients without
+// an amount
+type ingredientHeadingsExtractor struct{}
+
+func (ingredientHeadingsExtractor) Extract(page *goquery.Selection, raw map[string]interface{}) map[string]interface{} {
+    if raw == nil {
+        return nil
+    }
+
+    var ingredients []interface{}
+    for _, ingredient := range toStringList(raw["recipeIngredient"], false) {
+        if !isIngredientHeading(strings.TrimSpace(ingredient)) {
+            ingredients = append(ingredients, ingredient)
+        }
+    }
+
+    if ingredients != nil {
+        raw["recipeIngredient"] = ingredients
+    }
+
+    return raw
+}
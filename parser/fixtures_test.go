@@ -0,0 +1,171 @@
+package parser
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "flag"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "logit/models"
+)
+
+// go test ./parser -run TestFixtures -update rewrites the golden files after
+// an intended change to the extraction or the normalizers
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+const (
+    FIXTURE_DIR     = "testdata/fixtures"
+    GOLDEN_DIR      = "testdata/golden"
+    FIXTURE_HOST    = "http://fixtures.test"
+)
+
+// what a fixture is expected to produce, the error code is part of it so
+// pages without a (complete) recipe are covered too. Recipes is only set
+// for pages with more than one recipe, Ingredients for the text fixtures
+type fixtureOutput struct {
+    Code        string              `json:"code,omitempty"`
+    Recipe      models.Recipe       `json:"recipe"`
+    Recipes     []models.Recipe     `json:"recipes,omitempty"`
+    Ingredients []models.Ingredient `json:"ingredients,omitempty"`
+}
+
+func TestFixtures(t *testing.T) {
+    // the fixtures are served from localhost, as fast as it can
+    crawl := Crawl
+    Policy.AllowPrivate = true
+    Crawl.DomainDelay, Crawl.RandomDelay = 0, 0
+    defer func() {
+        Policy.AllowPrivate = false
+        Crawl = crawl
+    }()
+
+    server := httptest.NewServer(http.FileServer(http.Dir(FIXTURE_DIR)))
+    defer server.Close()
+
+    fixtures, err := filepath.Glob(filepath.Join(FIXTURE_DIR, "*.html"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(fixtures) == 0 {
+        t.Fatalf("no fixtures in %s", FIXTURE_DIR)
+    }
+
+    for _, fixture := range fixtures {
+        name := strings.TrimSuffix(filepath.Base(fixture), ".html")
+        t.Run(name, func(t *testing.T) {
+            // every fixture is scraped from scratch
+            Cache = NewMemoryCache()
+
+            recipe, err := FetchRecipe(server.URL+"/"+name+".html", []string{"logit-fixtures"})
+
+            output := fixtureOutput{Recipe: recipe}
+            var scrapeErr *ScrapeError
+            if errors.As(err, &scrapeErr) {
+                output.Code = scrapeErr.Code
+            } else if err != nil {
+                t.Fatalf("unexpected error: %+v", err)
+            }
+
+            if recipes, err := FetchAllRecipes(server.URL+"/"+name+".html", []string{"logit-fixtures"}); err == nil && len(recipes) > 1 {
+                output.Recipes = recipes
+            }
+
+            // relative links resolve against the server, which gets a new
+            // port every run
+            checkGolden(t, name, output, server.URL)
+        })
+    }
+}
+
+func TestTextFixtures(t *testing.T) {
+    // recipes pasted as plain text, see SegmentRecipeText
+    fixtures, err := filepath.Glob(filepath.Join(FIXTURE_DIR, "*.txt"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, fixture := range fixtures {
+        name := strings.TrimSuffix(filepath.Base(fixture), ".txt")
+        t.Run(name, func(t *testing.T) {
+            text, err := os.ReadFile(fixture)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            var output fixtureOutput
+            recipe, ok := ParseRecipeText(string(text))
+            if ok {
+                output.Recipe = recipe
+                output.Ingredients = ParseIngredientList(recipe.Ingredients)
+            } else {
+                output.Code = NO_RECIPE_FOUND
+            }
+
+            checkGolden(t, name, output, "")
+        })
+    }
+}
+
+func checkGolden(t *testing.T, name string, output fixtureOutput, serverURL string) {
+    // REQUIRES:    t, name of the fixture, output
+    // MODIFIES:    the golden file with -update
+    // EFFECTS:     Fails [t] if [output] isn't what the fixture's golden file
+    //              says. [serverURL] is replaced with FIXTURE_HOST
+
+    got, err := json.MarshalIndent(output, "", "  ")
+    if err != nil {
+        t.Fatal(err)
+    }
+    got = append(got, '\n')
+    if serverURL != "" {
+        got = bytes.ReplaceAll(got, []byte(serverURL), []byte(FIXTURE_HOST))
+    }
+
+    golden := filepath.Join(GOLDEN_DIR, name+".json")
+    if *update {
+        if err := os.WriteFile(golden, got, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    want, err := os.ReadFile(golden)
+    if err != nil {
+        t.Fatalf("missing golden file, run with -update to create it: %+v", err)
+    }
+
+    if !bytes.Equal(got, want) {
+        t.Errorf("output differs from %s, run with -update if the change is intended\n%s", golden, diffLines(string(want), string(got)))
+    }
+}
+
+func diffLines(want, got string) string {
+    // REQUIRES:    want, got
+    // MODIFIES:    none
+    // EFFECTS:     Returns the lines that differ between [want] and [got],
+    //              enough to see what changed without an external diff tool
+
+    wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+    var diff strings.Builder
+    for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+        var w, g string
+        if i < len(wantLines) {
+            w = wantLines[i]
+        }
+        if i < len(gotLines) {
+            g = gotLines[i]
+        }
+
+        if w != g {
+            diff.WriteString("- " + w + "\n+ " + g + "\n")
+        }
+    }
+
+    return diff.String()
+}
@@ -3,11 +3,13 @@ package parser
 import (
 	// misc.
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"logit/jobs"
 	"logit/models"
-	"math/rand"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/google/uuid"
 
@@ -16,13 +18,45 @@ import (
 	"github.com/gocolly/colly"
 )
 
+// the same callbacks run for every request on a collector, so each request
+// carries the result it fills in through its context
+const RESULT_KEY = "result"
+
+func resultOf(r *colly.Request) *scrapeResult {
+    result, _ := r.Ctx.GetAny(RESULT_KEY).(*scrapeResult)
+    return result
+}
+
 func requestHandler() colly.RequestCallback {
     return func(r *colly.Request) {
-        r.Headers.Set("Referer", "https://www.google.com")
+        if Crawl.UserAgentMode == UA_SPOOF {
+            r.Headers.Set("Referer", "https://www.google.com")
+        }
+
+        // revalidate a stale cache entry instead of downloading the page again
+        if cached := resultOf(r).cached; cached != nil {
+            if cached.ETag != "" {
+                r.Headers.Set("If-None-Match", cached.ETag)
+            }
+            if cached.LastModified != "" {
+                r.Headers.Set("If-Modified-Since", cached.LastModified)
+            }
+        }
+
         log.Printf("Visiting %s\n", r.URL)
     }
 }
 
+func responseHandler() colly.ResponseCallback {
+    return func(r *colly.Response) {
+        result := resultOf(r.Request)
+        result.url = r.Request.URL.String()
+        result.body = r.Body
+        result.etag = r.Headers.Get("ETag")
+        result.lastModified = r.Headers.Get("Last-Modified")
+    }
+}
+
 func scrapedHandler() colly.ScrapedCallback {
     return func(r *colly.Response) {
         log.Printf("Finished scraping %s\n", r.Request.URL)
@@ -31,63 +65,271 @@ func scrapedHandler() colly.ScrapedCallback {
 
 func errorHandler() colly.ErrorCallback {
     return func(r *colly.Response, err error) {
+        result := resultOf(r.Request)
+
+        // colly treats anything above 202 as an error, including a 304 from
+        // a conditional request
+        if r.StatusCode == http.StatusNotModified {
+            result.notModified = true
+            return
+        }
+
         log.Printf("Scraping error: %+v\n", err)
+        result.status = r.StatusCode
+        result.err = err
     }
 }
 
-func htmlHandler(recipe *map[string]interface{}, id *string) colly.HTMLCallback {
+func htmlHandler() colly.HTMLCallback {
     return func(h *colly.HTMLElement) {
+        result := resultOf(h.Request)
+
         var rawJSON interface{}
-        json.Unmarshal([]byte(h.Text), &rawJSON)
-        
-        data := FindRecipe(rawJSON)
+        if err := json.Unmarshal([]byte(h.Text), &rawJSON); err != nil {
+            log.Printf("[PARSER] ld+json error: %+v\n", err)
+            result.malformedJSON = true
+            return
+        }
+
+        // FetchRecipe returns the first recipe on the page, FetchAllRecipes
+        // all of them
+        recipes := FindRecipes(rawJSON)
+        if len(recipes) > 0 && len(result.raw) == 0 {
+            result.raw = recipes[0]
+            result.rawIndex = len(result.recipes)
+            result.id = uuid.New().String() 
+        }
+        result.recipes = append(result.recipes, recipes...)
+
+    }
+}
+
+func markupHandler() colly.HTMLCallback {
+    return func(h *colly.HTMLElement) {
+        result := resultOf(h.Request)
+
+        // ld+json always wins, microdata and RDFa are only a fallback for
+        // pages that don't publish a script tag
+        if len(result.raw) > 0 {
+            return
+        }
+
+        data := FindMarkupRecipe(h.DOM)
         if len(data) > 0 {
-            *recipe = data
-            *id = uuid.New().String() 
+            result.raw = data
+            result.id = uuid.New().String()
         }
+    }
+}
+
+func heuristicHandler() colly.HTMLCallback {
+    return func(h *colly.HTMLElement) {
+        result := resultOf(h.Request)
+        if len(result.raw) > 0 {
+            return
+        }
+
+        data := FindHeuristicRecipe(h.DOM)
+        if len(data) > 0 {
+            result.raw = data
+            result.id = uuid.New().String()
+            result.lowConfidence = true
+        }
+    }
+}
+
+func canonicalHandler() colly.HTMLCallback {
+    return func(h *colly.HTMLElement) {
+        if href := h.ChildAttr("link[rel='canonical']", "href"); href != "" {
+            resultOf(h.Request).canonical = h.Request.AbsoluteURL(href)
+        }
+    }
+}
 
+func calculateQuery(ctx *gin.Context) (models.ScrapeJobRequest, bool) {
+    // REQUIRES:    ctx
+    // MODIFIES:    ctx
+    // EFFECTS:     Reads the link along with the optional servings override,
+    //              which lets the user split the batch into a different number
+    //              of servings, and which of the page's recipes to return.
+    //              Responds with 400 and returns false if it's invalid
+
+    req := models.ScrapeJobRequest{
+        Link:   ctx.Query("link"),
+        Name:   ctx.Query("name"),
+    }
+
+    if servingsQuery := ctx.Query("servings"); servingsQuery != "" {
+        servings, err := strconv.ParseFloat(servingsQuery, 64)
+        if err != nil || servings <= 0 {
+            badQuery(ctx, "servings must be a positive number")
+            return req, false
+        }
+        req.Servings = servings
+    }
+
+    if allQuery := ctx.Query("all"); allQuery != "" {
+        all, err := strconv.ParseBool(allQuery)
+        if err != nil {
+            badQuery(ctx, "all must be true or false")
+            return req, false
+        }
+        req.All = all
+    }
+
+    if indexQuery := ctx.Query("index"); indexQuery != "" {
+        index, err := strconv.Atoi(indexQuery)
+        if err != nil || index < 0 {
+            badQuery(ctx, "index must be a number from 0")
+            return req, false
+        }
+        req.Index = &index
+    }
+
+    return req, true
+}
+
+func badQuery(ctx *gin.Context, message string) {
+    ctx.AbortWithStatusJSON(http.StatusBadRequest, models.Response[interface{}]{
+        Message: message,
+        Data: nil,
+        Status: http.StatusBadRequest,
+    })
+}
+
+func errorResponse(scrapeErr *ScrapeError) (int, interface{}) {
+    log.Printf("[PARSER] scrape error: %+v\n", scrapeErr)
+    return scrapeErr.Status, models.Response[interface{}]{
+        Message: scrapeErr.Message,
+        Data: nil,
+        Status: scrapeErr.Status,
+        Code: scrapeErr.Code,
+    }
+}
+
+func calculate(req models.ScrapeJobRequest, uagents []string) (int, interface{}) {
+    // REQUIRES:    req.Link, uagents
+    // MODIFIES:    Cache
+    // EFFECTS:     Fetches the recipe (or recipes) at [req.Link] and returns the
+    //              status and models.Response the calculate endpoints respond
+    //              with
+
+    if req.All {
+        return calculateAll(req, uagents)
+    }
+
+    var recipe models.Recipe
+    var err error
+    if req.Index != nil || req.Name != "" {
+        var recipes []models.Recipe
+        if recipes, err = FetchAllRecipes(req.Link, uagents); err == nil {
+            recipe, err = SelectRecipe(recipes, req.Index, req.Name)
+        }
+    } else {
+        recipe, err = FetchRecipe(req.Link, uagents)
+    }
+
+    var scrapeErr *ScrapeError
+    if errors.As(err, &scrapeErr) && scrapeErr.Code != PARTIAL_RECIPE {
+        return errorResponse(scrapeErr)
+    }
+
+    if req.Servings > 0 {
+        RescaleServings(&recipe, req.Servings)
+    }
+
+    res := models.Response[models.Recipe]{
+        Message: "recipe nutrition calculated!",
+        Data: recipe,
+        Status: http.StatusOK,
+    }
+
+    // still a usable recipe, but the app should say what's missing
+    if scrapeErr != nil {
+        res.Message = scrapeErr.Message
+        res.Code = scrapeErr.Code
+    }
+
+    return http.StatusOK, res
+}
+
+func calculateAll(req models.ScrapeJobRequest, uagents []string) (int, interface{}) {
+    recipes, err := FetchAllRecipes(req.Link, uagents)
+
+    var scrapeErr *ScrapeError
+    if errors.As(err, &scrapeErr) {
+        return errorResponse(scrapeErr)
+    }
+
+    if req.Servings > 0 {
+        for i := range recipes {
+            RescaleServings(&recipes[i], req.Servings)
+        }
+    }
+
+    return http.StatusOK, models.Response[[]models.Recipe]{
+        Message: fmt.Sprintf("found %d recipes!", len(recipes)),
+        Data: recipes,
+        Status: http.StatusOK,
     }
 }
 
 func CalculateHandler(uagents []string) gin.HandlerFunc {
     return func(ctx *gin.Context) {
-        randomIndx := rand.Intn(len(uagents))
-		uagent := uagents[randomIndx]
-        
-        rawRecipe := make(map[string]interface{}, 0)
-        id := ""
-       
-        // -------- COLLY CONFIG --------
-        c := colly.NewCollector(
-            colly.UserAgent(uagent),
-        )
-        c.Limit(&colly.LimitRule{
-            RandomDelay: 1 * time.Second,
-        })
-        
-        // -------- COLLY HANDLERS --------
-        c.OnRequest(requestHandler())
-        c.OnError(errorHandler()) 
-        c.OnScraped(scrapedHandler())
-        c.OnHTML("script[type='application/ld+json']", htmlHandler(&rawRecipe, &id))
-    
-        // -------- COLLY START --------
-        link := ctx.Query("link")
-        c.Visit(link)
-    
-        var recipe models.Recipe
-        bytes, _ := json.Marshal(rawRecipe)
-        json.Unmarshal(bytes, &recipe)
-        
-        // Normalize nutrition, image, and main entity data
-        recipe.Nutrition = NormalizeNutritionData(recipe.Nutrition)
-        recipe.Image = NormalizeImageData(recipe.Image)
-        recipe.MainEntity = NormalizeMainEntity(recipe.MainEntity)
-    
-        ctx.JSON(http.StatusOK, models.Response[models.Recipe]{
-            Message: "recipe nutrition calculated!",
-            Data: recipe,
+        req, ok := calculateQuery(ctx)
+        if !ok {
+            return
+        }
+
+        status, res := calculate(req, uagents)
+        if status != http.StatusOK {
+            ctx.AbortWithStatusJSON(status, res)
+            return
+        }
+
+        ctx.JSON(status, res)
+    } 
+}
+
+func CalculateJobHandler() gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        // same query as CalculateHandler, but responds right away with a job
+        // to poll instead of waiting for the site
+        req, ok := calculateQuery(ctx)
+        if !ok {
+            return
+        }
+
+        jobs.SubmitResponse(ctx, SCRAPE_JOB, req)
+    }
+}
+
+func BatchHandler(uagents []string) gin.HandlerFunc {
+    return func(ctx *gin.Context) {
+        var req models.BatchRequest
+        if err := ctx.ShouldBindJSON(&req); err != nil || len(req.Links) == 0 {
+            ctx.AbortWithStatusJSON(http.StatusBadRequest, models.Response[interface{}]{
+                Message: "expected a list of links",
+                Data: nil,
+                Status: http.StatusBadRequest,
+            })
+            return
+        }
+
+        if len(req.Links) > MAX_BATCH_SIZE {
+            ctx.AbortWithStatusJSON(http.StatusBadRequest, models.Response[interface{}]{
+                Message: fmt.Sprintf("at most %d links can be imported at once", MAX_BATCH_SIZE),
+                Data: nil,
+                Status: http.StatusBadRequest,
+            })
+            return
+        }
+
+        // each link has its own status and code, the batch itself succeeded
+        ctx.JSON(http.StatusOK, models.Response[[]models.BatchResult]{
+            Message: "recipes imported!",
+            Data: FetchRecipes(req.Links, uagents),
             Status: http.StatusOK,
         })
-    } 
+    }
 }
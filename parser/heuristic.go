@@ -0,0 +1,180 @@
+package parser
+
+import (
+    "regexp"
+    "strings"
+
+    "github.com/PuerkitoBio/goquery"
+)
+
+const HEADING_SELECTOR = "h1, h2, h3, h4, h5, h6"
+
+var ingredientHeadingExp = regexp.MustCompile(`(?i)\bingredients?\b`)
+
+func FindHeuristicRecipe(doc *goquery.Selection) map[string]interface{} {
+    // REQUIRES:    doc
+    // MODIFIES:    none
+    // EFFECTS:     Last resort for pages without any structured data. Reads the
+    //              Open Graph tags for the name, image and description and scans
+    //              for ingredient lists. Only returns a recipe if an ingredient
+    //              list was found, otherwise every page would look like a recipe
+
+    ingredients := findIngredientLists(doc)
+    if len(ingredients) == 0 {
+        return nil
+    }
+
+    recipe := map[string]interface{}{
+        "@type":            "Recipe",
+        "recipeIngredient": ingredients,
+    }
+
+    if name := firstNonEmpty(
+        metaContent(doc, "og:title"),
+        strings.TrimSpace(doc.Find("title").First().Text()),
+        strings.TrimSpace(doc.Find("h1").First().Text()),
+    ); name != "" {
+        recipe["name"] = name
+    }
+
+    if image := firstNonEmpty(
+        metaContent(doc, "og:image:secure_url"),
+        metaContent(doc, "og:image"),
+    ); image != "" {
+        recipe["image"] = image
+    }
+
+    if description := firstNonEmpty(
+        metaContent(doc, "og:description"),
+        metaContent(doc, "description"),
+    ); description != "" {
+        recipe["description"] = description
+    }
+
+    if link := firstNonEmpty(
+        metaContent(doc, "og:url"),
+        doc.Find("link[rel='canonical']").AttrOr("href", ""),
+    ); link != "" {
+        recipe["mainEntityOfPage"] = link
+    }
+
+    return recipe
+}
+
+func findIngredientLists(doc *goquery.Selection) []interface{} {
+    // REQUIRES:    doc
+    // MODIFIES:    none
+    // EFFECTS:     Looks for headings that mention ingredients and collects the
+    //              list items that follow them. If the page has no such heading,
+    //              falls back to lists whose class or id mentions ingredients
+
+    var ingredients []interface{}
+
+    doc.Find(HEADING_SELECTOR).EachWithBreak(func(_ int, heading *goquery.Selection) bool {
+        if !ingredientHeadingExp.MatchString(heading.Text()) {
+            return true
+        }
+
+        level := headingLevel(heading)
+        ingredients = listItemsAfter(heading, level)
+
+        // headings are often wrapped in their own container, in which case
+        // the list is a sibling of the wrapper instead
+        if len(ingredients) == 0 && heading.Siblings().Length() == 0 {
+            ingredients = listItemsAfter(heading.Parent(), level)
+        }
+
+        return len(ingredients) == 0
+    })
+
+    if len(ingredients) > 0 {
+        return ingredients
+    }
+
+    doc.Find("ul[class*='ngredient'], ol[class*='ngredient'], ul[id*='ngredient'], ol[id*='ngredient']").
+        First().
+        Find("li").
+        Each(func(_ int, li *goquery.Selection) {
+            if text := listItemText(li); text != "" {
+                ingredients = append(ingredients, text)
+            }
+        })
+
+    return ingredients
+}
+
+func listItemsAfter(s *goquery.Selection, level int) []interface{} {
+    // collect list items from the siblings after [s] up until the next
+    // heading of the same or a higher [level], also one wrapped in a
+    // container like [s] may be. Sub-headings such as "For the sauce" are
+    // allowed between lists
+    var items []interface{}
+
+    s.NextAll().EachWithBreak(func(_ int, sibling *goquery.Selection) bool {
+        if l := headingLevel(sibling); l != 0 && l <= level {
+            return false
+        }
+        if containsHeading(sibling, level) {
+            return false
+        }
+        if sibling.Is(HEADING_SELECTOR) && level == 0 && len(items) > 0 {
+            return false
+        }
+
+        lists := sibling.Filter("ul, ol").AddSelection(sibling.Find("ul, ol"))
+        lists.Find("li").Each(func(_ int, li *goquery.Selection) {
+            // nested lists get picked up by their own li
+            if li.Find("li").Length() > 0 {
+                return
+            }
+            if text := listItemText(li); text != "" {
+                items = append(items, text)
+            }
+        })
+
+        return true
+    })
+
+    return items
+}
+
+func containsHeading(s *goquery.Selection, level int) bool {
+    // whether [s] has a heading of [level] or higher inside, any heading if
+    // the level isn't known
+    found := false
+    s.Find(HEADING_SELECTOR).EachWithBreak(func(_ int, heading *goquery.Selection) bool {
+        found = level == 0 || headingLevel(heading) <= level
+        return !found
+    })
+
+    return found
+}
+
+func headingLevel(s *goquery.Selection) int {
+    name := goquery.NodeName(s)
+    if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+        return int(name[1] - '0')
+    }
+
+    return 0
+}
+
+func listItemText(li *goquery.Selection) string {
+    return strings.Join(strings.Fields(li.Text()), " ")
+}
+
+func metaContent(doc *goquery.Selection, name string) string {
+    // Open Graph uses property, but plenty of sites use name instead
+    selector := "meta[property='" + name + "'], meta[name='" + name + "']"
+    return strings.TrimSpace(doc.Find(selector).First().AttrOr("content", ""))
+}
+
+func firstNonEmpty(vals ...string) string {
+    for _, val := range vals {
+        if val != "" {
+            return val
+        }
+    }
+
+    return ""
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+    "log"
+    "math"
+    "net/url"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "logit/models"
+)
+
+// width / height the client's recipe cards are designed for, used to pick
+// between images of the same size
+const PREFERRED_ASPECT = 4.0 / 3.0
+
+// WordPress and most CDNs put the size in the file name, "dish-1200x800.jpg"
+var imageSizeExp = regexp.MustCompile(`[-_](\d{2,5})x(\d{2,5})\.[a-zA-Z]+(?:$|\?)`)
+
+func NormalizeImageData(img interface{}) []models.ImageCandidate {
+    // REQUIRES:    img
+    // MODIFIES:    none
+    // EFFECTS:     Collects every image url in [img], which can be a string,
+    //              an ImageObject or a list of either, and orders them best
+    //              first: the largest, then the one closest to the preferred
+    //              aspect ratio, then the order the site listed them in. Images
+    //              without a known size go last. Returns nil if there are none
+
+    var candidates []models.ImageCandidate
+    seen := map[string]bool{}
+    collectImages(img, &candidates, seen)
+
+    sort.SliceStable(candidates, func(i, j int) bool {
+        a, b := candidates[i], candidates[j]
+        areaA, areaB := a.Width*a.Height, b.Width*b.Height
+        if areaA != areaB {
+            return areaA > areaB
+        }
+
+        return aspectDistance(a) < aspectDistance(b)
+    })
+
+    return candidates
+}
+
+func collectImages(img interface{}, candidates *[]models.ImageCandidate, seen map[string]bool) {
+    switch img := img.(type) {
+    case nil:
+    case string:
+        addImage(models.ImageCandidate{Url: img}, candidates, seen)
+    case map[string]interface{}:
+        link := toString(img["url"])
+        if link == "" {
+            link = toString(img["contentUrl"])
+        }
+
+        addImage(models.ImageCandidate{
+            Url:    link,
+            Width:  imageDimension(img["width"]),
+            Height: imageDimension(img["height"]),
+        }, candidates, seen)
+    case []interface{}:
+        for _, item := range img {
+            collectImages(item, candidates, seen)
+        }
+    default:
+        log.Printf("[PARSER] image normalization: encountered type %T\n", img)
+    }
+}
+
+func addImage(image models.ImageCandidate, candidates *[]models.ImageCandidate, seen map[string]bool) {
+    if image.Url == "" || seen[image.Url] {
+        return
+    }
+    seen[image.Url] = true
+
+    if image.Width == 0 || image.Height == 0 {
+        if match := imageSizeExp.FindStringSubmatch(image.Url); match != nil {
+            image.Width, _ = strconv.Atoi(match[1])
+            image.Height, _ = strconv.Atoi(match[2])
+        }
+    }
+
+    *candidates = append(*candidates, image)
+}
+
+func imageDimension(val interface{}) int {
+    // "1200", 1200, "1200px" or a QuantitativeValue
+    switch val := val.(type) {
+    case map[string]interface{}:
+        return imageDimension(val["value"])
+    case string:
+        return int(toFloat(strings.TrimSuffix(strings.TrimSpace(val), "px")))
+    }
+
+    return int(toFloat(val))
+}
+
+func aspectDistance(image models.ImageCandidate) float64 {
+    if image.Width == 0 || image.Height == 0 {
+        return math.Inf(1)
+    }
+
+    return math.Abs(float64(image.Width)/float64(image.Height) - PREFERRED_ASPECT)
+}
+
+func ResolveImages(recipe *models.Recipe, base string) {
+    // REQUIRES:    recipe, base is the url of the page the recipe is from
+    // MODIFIES:    recipe
+    // EFFECTS:     Makes the image urls absolute, sites often use relative
+    //              or protocol relative ones, and sets recipe.Image to the
+    //              best of them
+
+    baseURL, err := url.Parse(base)
+    if err != nil {
+        baseURL = nil
+    }
+
+    for i, image := range recipe.Images {
+        if u, err := url.Parse(image.Url); err == nil && baseURL != nil {
+            recipe.Images[i].Url = baseURL.ResolveReference(u).String()
+        }
+    }
+
+    if len(recipe.Images) > 0 {
+        recipe.Image = recipe.Images[0].Url
+    } else {
+        recipe.Image = nil
+    }
+}
@@ -0,0 +1,250 @@
+package parser
+
+import (
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "logit/models"
+    "logit/units"
+)
+
+// the unit of an amount without one, "2 eggs"
+const UNIT_WHOLE = "whole"
+
+// spelled out amounts, "a pinch of salt", "two eggs"
+var ingredientNumberWords = map[string]float64{
+    "a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+    "seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+var (
+    // "1 1/2", "1/2", "1,000", "1.5", "1,5" and the words above. A comma
+    // before three digits separates thousands, any other one is a decimal
+    // comma. SanitizeText has already turned "1½" into "1 1/2"
+    ingredientNumberPattern = `\d+\s+\d+/\d+|\d+/\d+|\d{1,3}(?:,\d{3})+\b(?:\.\d+)?|\d+(?:[.,]\d+)?|` +
+        wordsPattern(ingredientNumberWords)
+    ingredientThousandsExp = regexp.MustCompile(`^\d{1,3}(?:,\d{3})+(?:\.\d+)?$`)
+    ingredientUnitPattern = unitsPattern(units.Spellings()) + `|(?-i:` + unitsPattern(units.ExactSpellings()) + `)`
+    // an amount at the start of the line: "about 2-3 cups of", "200g",
+    // "1 15-ounce"
+    ingredientAmountExp = regexp.MustCompile(`(?i)^(?:about|approx\.?|approximately|~)?\s*(` + ingredientNumberPattern + `)` +
+        `(?:\s*(?:-|–|—|to|or)\s*(` + ingredientNumberPattern + `))?` +
+        `(?:\s*-?\s*(` + ingredientUnitPattern + `)\b\.?)?(?:\s+of\b)?\s*`)
+    // a unit that follows a size, the "can" of "1 (14 oz) can"
+    ingredientUnitExp = regexp.MustCompile(`(?i)^(` + ingredientUnitPattern + `)\b\.?(?:\s+of\b)?\s*`)
+    // what can join the amounts of "1 lb 2 oz" or "1 cup / 240 ml"
+    ingredientJoinExp = regexp.MustCompile(`(?i)^(?:\+|/|plus\b|and\b)?\s*`)
+    ingredientParenExp = regexp.MustCompile(`\(([^()]*)\)`)
+    ingredientCountSizeExp = regexp.MustCompile(`(?i)^(?:` + ingredientNumberPattern + `)\s*\(`)
+    // what "a" can't be the amount of, "a few sprigs"
+    ingredientVagueExp = regexp.MustCompile(`(?i)^(few|little|bit|couple)\b`)
+    // notes that end a line without a comma, "salt to taste"
+    ingredientNoteExp = regexp.MustCompile(`(?i)\s+(to taste|as needed|for serving|for garnish|optional)$`)
+)
+
+func wordsPattern(words map[string]float64) string {
+    keys := make([]string, 0, len(words))
+    for word := range words {
+        keys = append(keys, word)
+    }
+    // "an" has to be tried before "a"
+    sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+
+    return `\b(?:` + strings.Join(keys, "|") + `)\b`
+}
+
+func unitsPattern(spellings []string) string {
+    for i, spelling := range spellings {
+        spellings[i] = regexp.QuoteMeta(spelling)
+    }
+    // longest first, so "cups" isn't read as "c" and "fl oz" as "fl"
+    sort.Slice(spellings, func(i, j int) bool {
+        if len(spellings[i]) != len(spellings[j]) {
+            return len(spellings[i]) > len(spellings[j])
+        }
+        return spellings[i] < spellings[j]
+    })
+
+    return strings.Join(spellings, "|")
+}
+
+func ParseIngredient(line string) models.Ingredient {
+    // REQUIRES:    line
+    // MODIFIES:    none
+    // EFFECTS:     Splits an ingredient line like "1 1/2 cups all-purpose
+    //              flour, sifted" into its name, amounts and modifier, the
+    //              way the ingredient parser service at PARSER_API_URL does.
+    //              Handles mixed numbers, unicode fractions, ranges, spelled
+    //              out numbers, metric and imperial units and amounts in
+    //              parentheses, "1 cup (240 ml) milk". Amounts without a unit
+    //              are UNIT_WHOLE. A line without an amount has none. The
+    //              amounts after the first say how they relate to it, sizes
+    //              of a container like "1 (14 oz) can" are AMOUNT_PER_UNIT.
+    //              E.g. "2-3 large (about 1 lb) potatoes, peeled" is "large
+    //              potatoes", 2-3 whole and alternatively 1 lb, "peeled"
+
+    line = textBulletExp.ReplaceAllString(SanitizeText(line), "")
+
+    // "2 (15 oz) cans", a size right after the count is what each holds
+    countSize := ingredientCountSizeExp.MatchString(line)
+
+    // sizes in parentheses are amounts, anything else in them is a note
+    var sizes []models.Amount
+    var notes []string
+    line = ingredientParenExp.ReplaceAllStringFunc(line, func(paren string) string {
+        inner := strings.TrimSpace(paren[1 : len(paren)-1])
+        if amounts, rest := parseAmounts(inner); len(amounts) > 0 && rest == "" && amounts[0].Unit != UNIT_WHOLE {
+            sizes = append(sizes, amounts...)
+        } else if inner != "" {
+            notes = append(notes, inner)
+        }
+        return " "
+    })
+    line = strings.Join(strings.Fields(line), " ")
+
+    amounts, rest := parseAmounts(line)
+
+    // "1 (14 oz) can" and "1 15-ounce can" are one can of 14 or 15 oz,
+    // otherwise the sizes are the amount in other units
+    relation := models.AMOUNT_ALTERNATIVE
+    if len(amounts) > 0 && amounts[0].Unit == UNIT_WHOLE {
+        if match := ingredientUnitExp.FindStringSubmatch(rest); match != nil {
+            amounts[0].Unit = units.Normalize(match[1])
+            rest = rest[len(match[0]):]
+            relation = models.AMOUNT_PER_UNIT
+            for i := 1; i < len(amounts); i++ {
+                amounts[i].Relation = relation
+            }
+        }
+    }
+    if len(amounts) > 0 && countSize {
+        if unit, ok := units.Lookup(amounts[0].Unit); ok && unit.Kind == units.COUNT {
+            relation = models.AMOUNT_PER_UNIT
+        }
+    }
+    for i := range sizes {
+        sizes[i].Relation = relation
+    }
+    // the service answers [] rather than null
+    amounts = append(append([]models.Amount{}, amounts...), sizes...)
+
+    name, modifier, _ := strings.Cut(rest, ",")
+    if match := ingredientNoteExp.FindStringSubmatch(name); match != nil {
+        name = strings.TrimSuffix(name, match[0])
+        notes = append(notes, match[1])
+    }
+    if modifier = strings.Trim(modifier, " ,."); modifier != "" {
+        notes = append([]string{modifier}, notes...)
+    }
+
+    return models.Ingredient{
+        Name:       strings.Trim(name, " ,.;:-"),
+        Amounts:    amounts,
+        Modifier:   strings.Join(notes, ", "),
+    }
+}
+
+func ParseIngredientList(lines []string) []models.Ingredient {
+    // REQUIRES:    lines
+    // MODIFIES:    none
+    // EFFECTS:     Returns ParseIngredient of every line, in the same order
+
+    ingredients := make([]models.Ingredient, 0, len(lines))
+    for _, line := range lines {
+        ingredients = append(ingredients, ParseIngredient(line))
+    }
+
+    return ingredients
+}
+
+func parseAmounts(text string) ([]models.Amount, string) {
+    // REQUIRES:    text
+    // MODIFIES:    none
+    // EFFECTS:     Returns the amounts [text] starts with and what follows
+    //              them. Amounts after the first need a unit, so "1 cup
+    //              2 eggs" stays one amount. They're added to the first,
+    //              "1 lb 2 oz", unless a slash makes them an alternative,
+    //              "1 cup / 240 ml"
+
+    var amounts []models.Amount
+    for {
+        rest := text
+        relation := ""
+        if len(amounts) > 0 {
+            join := ingredientJoinExp.FindString(text)
+            rest = text[len(join):]
+            relation = models.AMOUNT_ADDITIVE
+            if strings.TrimSpace(join) == "/" {
+                relation = models.AMOUNT_ALTERNATIVE
+            }
+        }
+
+        match := ingredientAmountExp.FindStringSubmatch(rest)
+        if match == nil || (len(amounts) > 0 && match[3] == "") {
+            return amounts, text
+        }
+
+        after := rest[len(match[0]):]
+        word := strings.ToLower(match[1])
+        // "2% milk" and "a few sprigs" aren't amounts
+        if strings.HasPrefix(after, "%") || ((word == "a" || word == "an") &&
+            match[3] == "" && ingredientVagueExp.MatchString(after)) {
+            return amounts, text
+        }
+
+        value, ok := parseNumber(match[1])
+        if !ok {
+            return amounts, text
+        }
+
+        amount := models.Amount{Unit: UNIT_WHOLE, Value: float32(value), Relation: relation}
+        if match[3] != "" {
+            amount.Unit = units.Normalize(match[3])
+        }
+        if match[2] != "" {
+            if upper, ok := parseNumber(match[2]); ok && upper > value {
+                amount.UpperValue = float32(upper)
+            }
+        }
+
+        amounts = append(amounts, amount)
+        text = after
+    }
+}
+
+func parseNumber(text string) (float64, bool) {
+    // "1 1/2", "1/2", "1,000", "1,5" or a word from ingredientNumberWords
+    text = strings.ToLower(strings.TrimSpace(text))
+    if value, exists := ingredientNumberWords[text]; exists {
+        return value, true
+    }
+
+    total := 0.0
+    for _, part := range strings.Fields(text) {
+        if numerator, denominator, isFraction := strings.Cut(part, "/"); isFraction {
+            n, err := strconv.ParseFloat(numerator, 64)
+            d, derr := strconv.ParseFloat(denominator, 64)
+            if err != nil || derr != nil || d == 0 {
+                return 0, false
+            }
+            total += n / d
+            continue
+        }
+
+        if ingredientThousandsExp.MatchString(part) {
+            part = strings.ReplaceAll(part, ",", "")
+        } else {
+            part = strings.Replace(part, ",", ".", 1)
+        }
+
+        value, err := strconv.ParseFloat(part, 64)
+        if err != nil {
+            return 0, false
+        }
+        total += value
+    }
+
+    return total, total > 0
+}
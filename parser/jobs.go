@@ -0,0 +1,32 @@
+package parser
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "logit/jobs"
+    "logit/models"
+)
+
+const SCRAPE_JOB = "scrape"
+
+func RegisterJobs(uagents []string) {
+    // REQUIRES:    uagents
+    // MODIFIES:    jobs
+    // EFFECTS:     Lets the job workers run the scrape jobs submitted by
+    //              CalculateJobHandler
+
+    jobs.Register(SCRAPE_JOB, func(payload []byte, progress func(int, string)) (int, interface{}) {
+        var req models.ScrapeJobRequest
+        if err := json.Unmarshal(payload, &req); err != nil {
+            return http.StatusBadRequest, models.Response[interface{}]{
+                Message: "doesn't follow expected input format",
+                Data: nil,
+                Status: http.StatusBadRequest,
+            }
+        }
+
+        progress(10, "fetching recipe")
+        return calculate(req, uagents)
+    })
+}
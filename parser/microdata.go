@@ -0,0 +1,184 @@
+package parser
+
+import (
+    "strings"
+
+    "github.com/PuerkitoBio/goquery"
+)
+
+// markupSyntax describes the attributes a structured data syntax uses to
+// open an item, declare its type and name its properties. Microdata and
+// RDFa describe the same schema.org vocabulary as ld+json, just inline.
+type markupSyntax struct {
+    scope       string
+    itemType    string
+    prop        string
+}
+
+var (
+    microdataSyntax = markupSyntax{scope: "itemscope", itemType: "itemtype", prop: "itemprop"}
+    rdfaSyntax      = markupSyntax{scope: "typeof", itemType: "typeof", prop: "property"}
+)
+
+// properties that should always be lists, even when a page only marks up
+// a single value, so they match the ld+json shape
+var markupListProps = map[string]bool{
+    "recipeIngredient":     true,
+    "recipeInstructions":   true,
+}
+
+// older microdata uses names that were later renamed in schema.org
+var markupPropAliases = map[string]string{
+    "ingredients": "recipeIngredient",
+}
+
+func FindMarkupRecipe(doc *goquery.Selection) map[string]interface{} {
+    // REQUIRES:    doc
+    // MODIFIES:    none
+    // EFFECTS:     Walks the microdata and RDFa items on the page and returns
+    //              the first one that is a recipe, using the same selection
+    //              rules as FindRecipe
+
+    var items []interface{}
+    items = append(items, ExtractMarkupItems(doc, microdataSyntax)...)
+    items = append(items, ExtractMarkupItems(doc, rdfaSyntax)...)
+
+    if len(items) == 0 {
+        return nil
+    }
+
+    return FindRecipe(items)
+}
+
+func ExtractMarkupItems(doc *goquery.Selection, syntax markupSyntax) []interface{} {
+    // REQUIRES:    doc, syntax
+    // MODIFIES:    none
+    // EFFECTS:     Converts every item declared with [syntax] into a map that
+    //              mirrors the ld+json structure. Nested items are returned
+    //              both inside their parent and on their own, in document
+    //              order, so FindRecipe can select a recipe at any depth
+
+    var items []interface{}
+    doc.Find("[" + syntax.scope + "]").Each(func(_ int, s *goquery.Selection) {
+        items = append(items, buildMarkupItem(s, syntax))
+    })
+
+    return items
+}
+
+func buildMarkupItem(s *goquery.Selection, syntax markupSyntax) map[string]interface{} {
+    item := map[string]interface{}{}
+
+    if types := markupTypes(s.AttrOr(syntax.itemType, "")); len(types) == 1 {
+        item["@type"] = types[0]
+    } else if len(types) > 1 {
+        item["@type"] = types
+    }
+
+    collectMarkupProps(s, syntax, item)
+    return item
+}
+
+func collectMarkupProps(s *goquery.Selection, syntax markupSyntax, item map[string]interface{}) {
+    // walk children until we hit another item's boundary. Properties on the
+    // boundary element belong to us, everything beneath it belongs to the
+    // nested item
+    s.Children().Each(func(_ int, child *goquery.Selection) {
+        _, isScope := child.Attr(syntax.scope)
+
+        if props, exists := child.Attr(syntax.prop); exists {
+            var val interface{}
+            if isScope {
+                val = buildMarkupItem(child, syntax)
+            } else {
+                val = markupValue(child)
+            }
+
+            for _, prop := range strings.Fields(props) {
+                addMarkupProp(item, markupName(prop), val)
+            }
+        }
+
+        if !isScope {
+            collectMarkupProps(child, syntax, item)
+        }
+    })
+}
+
+func addMarkupProp(item map[string]interface{}, prop string, val interface{}) {
+    if alias, exists := markupPropAliases[prop]; exists {
+        prop = alias
+    }
+
+    existing, exists := item[prop]
+    switch {
+    case !exists && markupListProps[prop]:
+        item[prop] = []interface{}{val}
+    case !exists:
+        item[prop] = val
+    default:
+        if list, ok := existing.([]interface{}); ok {
+            item[prop] = append(list, val)
+        } else {
+            item[prop] = []interface{}{existing, val}
+        }
+    }
+}
+
+func markupValue(s *goquery.Selection) string {
+    // REQUIRES:    s
+    // MODIFIES:    none
+    // EFFECTS:     Reads a property value the way the microdata spec does:
+    //              explicit content first, then the URL or machine readable
+    //              attribute of the element, and finally its text
+
+    if content, exists := s.Attr("content"); exists {
+        return strings.TrimSpace(content)
+    }
+
+    var attrs []string
+    switch goquery.NodeName(s) {
+    case "img", "audio", "video", "source", "embed", "iframe", "track":
+        attrs = []string{"src"}
+    case "a", "area", "link":
+        attrs = []string{"href"}
+    case "object":
+        attrs = []string{"data"}
+    case "time":
+        attrs = []string{"datetime"}
+    case "data", "meter":
+        attrs = []string{"value"}
+    }
+
+    // RDFa can also point to a value with resource
+    attrs = append(attrs, "resource")
+
+    for _, attr := range attrs {
+        if val, exists := s.Attr(attr); exists && val != "" {
+            return strings.TrimSpace(val)
+        }
+    }
+
+    return strings.Join(strings.Fields(s.Text()), " ")
+}
+
+func markupTypes(attr string) []interface{} {
+    // itemtype="https://schema.org/Recipe" or typeof="schema:Recipe"
+    var types []interface{}
+    for _, t := range strings.Fields(attr) {
+        types = append(types, markupName(t))
+    }
+
+    return types
+}
+
+func markupName(name string) string {
+    // strips vocabulary urls and prefixes so that both
+    // "http://schema.org/recipeIngredient" and "schema:recipeIngredient"
+    // become "recipeIngredient"
+    if i := strings.LastIndexAny(name, "/#:"); i != -1 {
+        return name[i+1:]
+    }
+
+    return name
+}
@@ -0,0 +1,205 @@
+package parser
+
+import (
+    "log"
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "logit/models"
+)
+
+// matches "250 kJ", "1,200 mg", "0.5g", "<1 g", "less than 1 gram" or "1,5 g"
+const NUTRIENT_REGEX = `(?i)(<|less than|under)?\s*(\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:[.,]\d+)?|[.,]\d+)\s*` +
+    `(kcal|kilocalories?|calories?|cals?|kj|kilojoules?|mg|milligrams?|µg|μg|mcg|ug|micrograms?|g|grams?|gr)?\b`
+
+const KJ_PER_KCAL = 4.184
+
+var nutrientExp = regexp.MustCompile(NUTRIENT_REGEX)
+
+// multipliers that convert a mass unit into grams
+var gramsPerUnit = map[string]float64{
+    "g":  1,
+    "mg": 1e-3,
+    "µg": 1e-6,
+}
+
+func canonicalNutrientUnit(unit string) string {
+    switch strings.ToLower(unit) {
+    case "kcal", "kilocalorie", "kilocalories", "calorie", "calories", "cal", "cals":
+        return "kcal"
+    case "kj", "kilojoule", "kilojoules":
+        return "kJ"
+    case "mg", "milligram", "milligrams":
+        return "mg"
+    case "µg", "μg", "mcg", "ug", "microgram", "micrograms":
+        return "µg"
+    case "g", "gram", "grams", "gr":
+        return "g"
+    }
+
+    return ""
+}
+
+func ConvertNutrientQty(qty float64, from, to string) (float64, bool) {
+    // REQUIRES:    from, to are canonical units
+    // MODIFIES:    none
+    // EFFECTS:     Converts [qty] between energy units or between mass units.
+    //              Returns false if the units measure different things
+
+    if from == to {
+        return qty, true
+    }
+
+    switch {
+    case from == "kJ" && to == "kcal":
+        return qty / KJ_PER_KCAL, true
+    case from == "kcal" && to == "kJ":
+        return qty * KJ_PER_KCAL, true
+    }
+
+    fromGrams, fromMass := gramsPerUnit[from]
+    toGrams, toMass := gramsPerUnit[to]
+    if fromMass && toMass {
+        return qty * fromGrams / toGrams, true
+    }
+
+    return qty, false
+}
+
+func ParseNutrient(key, val string) (models.Nutrient, bool) {
+    // REQUIRES:    key, val
+    // MODIFIES:    none
+    // EFFECTS:     Parses the value and unit written in [val] and converts it
+    //              into the unit models.Nutrition uses for [key]. When the
+    //              site left out the unit, the expected unit is assumed
+
+    nutrient := models.Nutrient{
+        Name:   CreateName(key),
+        Unit:   GetUnit(key),
+        Raw:    val,
+    }
+
+    match := nutrientExp.FindStringSubmatch(val)
+    if match == nil {
+        return nutrient, false
+    }
+
+    qty, err := parseNutrientNumber(match[2])
+    if err != nil {
+        log.Printf("[PARSER] nutrient %s: %+v\n", key, err)
+        return nutrient, false
+    }
+
+    nutrient.LessThan = match[1] != ""
+
+    if unit := canonicalNutrientUnit(match[3]); unit != "" {
+        converted, ok := ConvertNutrientQty(qty, unit, nutrient.Unit)
+        if !ok {
+            log.Printf("[PARSER] nutrient %s: can't convert %s to %s\n", key, unit, nutrient.Unit)
+            return nutrient, false
+        }
+        qty = converted
+    }
+
+    // avoid float noise such as 0.009999999 from unit conversions
+    nutrient.Qty = math.Round(qty*1e4) / 1e4
+    return nutrient, true
+}
+
+func parseNutrientNumber(num string) (float64, error) {
+    // "1,200" uses a thousands separator, "1,5" a decimal comma
+    if strings.Count(num, ",") == 1 && len(num)-strings.Index(num, ",") <= 3 && !strings.Contains(num, ".") {
+        num = strings.Replace(num, ",", ".", 1)
+    } else {
+        num = strings.ReplaceAll(num, ",", "")
+    }
+
+    return strconv.ParseFloat(num, 64)
+}
+
+func NormalizeNutritionData(nutrition interface{}) *models.NutritionInfo {
+    // REQUIRES:    nutrition
+    // MODIFIES:    none
+    // EFFECTS:     Converts the NutritionInformation schema into a typed
+    //              models.NutritionInfo. Every nutrient is converted into the
+    //              unit /log expects and summarized into a models.Nutrition.
+    //              Returns nil if the recipe has no nutrition
+
+    data, ok := nutrition.(map[string]interface{})
+    if !ok {
+        if nutrition != nil {
+            log.Printf("[PARSER] nutrition normalization: encountered type %T\n", nutrition)
+        }
+        return nil
+    }
+
+    info := &models.NutritionInfo{
+        Nutrients: map[string]models.Nutrient{},
+    }
+
+    for key, val := range data {
+        if strings.HasPrefix(key, "@") || val == nil {
+            continue
+        }
+
+        if key == "servingSize" {
+            info.ServingSize = toString(val)
+            continue
+        }
+
+        if nutrient, ok := ParseNutrient(key, toString(val)); ok {
+            info.Nutrients[key] = nutrient
+        }
+    }
+
+    info.Summary = SummarizeNutrients(info.Nutrients)
+    return info
+}
+
+func SummarizeNutrients(nutrients map[string]models.Nutrient) models.Nutrition {
+    // REQUIRES:    nutrients, keyed by their schema.org name
+    // MODIFIES:    none
+    // EFFECTS:     Maps the nutrients onto the fields /log and /create accept
+
+    return models.Nutrition{
+        Calories:      nutrients["calories"].Qty,
+        Fat:           nutrients["fatContent"].Qty,
+        TransFat:      nutrients["transFatContent"].Qty,
+        SaturatedFat:  nutrients["saturatedFatContent"].Qty,
+        Cholesterol:   nutrients["cholesterolContent"].Qty,
+        Sodium:        nutrients["sodiumContent"].Qty,
+        Carbohydrates: nutrients["carbohydrateContent"].Qty,
+        Fiber:         nutrients["fiberContent"].Qty,
+        Sugar:         nutrients["sugarContent"].Qty,
+        Protein:       nutrients["proteinContent"].Qty,
+    }
+}
+
+func nutrientsOf(summary models.Nutrition) map[string]models.Nutrient {
+    // the reverse of SummarizeNutrients, for nutrition we computed ourselves
+    values := map[string]float64{
+        "calories":             summary.Calories,
+        "fatContent":           summary.Fat,
+        "transFatContent":      summary.TransFat,
+        "saturatedFatContent":  summary.SaturatedFat,
+        "cholesterolContent":   summary.Cholesterol,
+        "sodiumContent":        summary.Sodium,
+        "carbohydrateContent":  summary.Carbohydrates,
+        "fiberContent":         summary.Fiber,
+        "sugarContent":         summary.Sugar,
+        "proteinContent":       summary.Protein,
+    }
+
+    nutrients := make(map[string]models.Nutrient, len(values))
+    for key, qty := range values {
+        nutrients[key] = models.Nutrient{
+            Name:   CreateName(key),
+            Qty:    math.Round(qty*1e4) / 1e4,
+            Unit:   GetUnit(key),
+        }
+    }
+
+    return nutrients
+}
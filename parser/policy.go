@@ -0,0 +1,193 @@
+package parser
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/gocolly/colly"
+)
+
+// URLPolicy decides which links the scraper is allowed to fetch. Links come
+// straight from users, so without it anyone could make the server request
+// internal services or cloud metadata endpoints
+type URLPolicy struct {
+    // allows loopback and private addresses, only for tests and local dev
+    AllowPrivate    bool
+    MaxBodySize     int
+    Timeout         time.Duration
+    MaxRedirects    int
+}
+
+var Policy = URLPolicy{
+    AllowPrivate:   false,
+    MaxBodySize:    5 * 1024 * 1024,
+    Timeout:        15 * time.Second,
+    MaxRedirects:   5,
+}
+
+// ranges that aren't covered by the net.IP helpers
+var reservedNets = []*net.IPNet{
+    mustParseCIDR("0.0.0.0/8"),       // "this" network
+    mustParseCIDR("100.64.0.0/10"),   // carrier-grade NAT
+    mustParseCIDR("192.0.0.0/24"),    // IETF protocol assignments
+    mustParseCIDR("198.18.0.0/15"),   // benchmarking
+    mustParseCIDR("240.0.0.0/4"),     // reserved, includes broadcast
+    mustParseCIDR("64:ff9b::/96"),    // NAT64, can embed private IPv4
+}
+
+// BlockedURLError is returned when a link or one of its redirects is not
+// allowed by the policy
+type BlockedURLError struct {
+    Link    string
+    Reason  string
+}
+
+func (e *BlockedURLError) Error() string {
+    return fmt.Sprintf("link %q is not allowed: %s", e.Link, e.Reason)
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+    _, n, err := net.ParseCIDR(cidr)
+    if err != nil {
+        panic(err)
+    }
+
+    return n
+}
+
+func (p URLPolicy) IsAllowedIP(ip net.IP) bool {
+    // REQUIRES:    ip
+    // MODIFIES:    none
+    // EFFECTS:     Returns false for loopback, private, link-local (which
+    //              includes the 169.254.169.254 metadata endpoint), multicast,
+    //              unspecified and reserved addresses
+
+    if p.AllowPrivate {
+        return true
+    }
+
+    if ip4 := ip.To4(); ip4 != nil {
+        ip = ip4
+    }
+
+    if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+    ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+    ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+        return false
+    }
+
+    for _, n := range reservedNets {
+        if n.Contains(ip) {
+            return false
+        }
+    }
+
+    return true
+}
+
+func (p URLPolicy) ValidateURL(link string) (*url.URL, error) {
+    // REQUIRES:    link
+    // MODIFIES:    none
+    // EFFECTS:     Checks that [link] is an absolute http(s) URL whose host
+    //              resolves only to public addresses. The dialer checks the
+    //              address again when connecting, which also covers redirects
+    //              and DNS answers that change in between
+
+    u, err := url.Parse(strings.TrimSpace(link))
+    if err != nil {
+        return nil, &BlockedURLError{Link: link, Reason: "not a valid url"}
+    }
+
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return nil, &BlockedURLError{Link: link, Reason: "only http and https links are supported"}
+    }
+
+    if u.Hostname() == "" {
+        return nil, &BlockedURLError{Link: link, Reason: "missing host"}
+    }
+
+    if u.User != nil {
+        return nil, &BlockedURLError{Link: link, Reason: "credentials in links are not supported"}
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+    defer cancel()
+
+    addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+    if err != nil {
+        return nil, &BlockedURLError{Link: link, Reason: "host could not be resolved"}
+    }
+
+    for _, addr := range addrs {
+        if !p.IsAllowedIP(addr.IP) {
+            return nil, &BlockedURLError{Link: link, Reason: "host resolves to a private address"}
+        }
+    }
+
+    return u, nil
+}
+
+func (p URLPolicy) transport() *http.Transport {
+    dialer := &net.Dialer{
+        Timeout: p.Timeout,
+        // runs on the resolved address right before connecting
+        Control: func(network, address string, _ syscall.RawConn) error {
+            host, _, err := net.SplitHostPort(address)
+            if err != nil {
+                return err
+            }
+
+            if ip := net.ParseIP(host); ip == nil || !p.IsAllowedIP(ip) {
+                return &BlockedURLError{Link: address, Reason: "connects to a private address"}
+            }
+
+            return nil
+        },
+    }
+
+    return &http.Transport{
+        // no proxy, otherwise the dialer would check the proxy's address
+        Proxy:                  nil,
+        DialContext:            dialer.DialContext,
+        TLSHandshakeTimeout:    p.Timeout,
+        ResponseHeaderTimeout:  p.Timeout,
+        MaxIdleConns:           10,
+        IdleConnTimeout:        30 * time.Second,
+    }
+}
+
+func (p URLPolicy) redirectHandler(req *http.Request, via []*http.Request) error {
+    if len(via) >= p.MaxRedirects {
+        return fmt.Errorf("stopped after %d redirects", p.MaxRedirects)
+    }
+
+    if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+        return &BlockedURLError{Link: req.URL.String(), Reason: "redirects to an unsupported scheme"}
+    }
+
+    // carry the headers over like colly does when there's no handler
+    for name, vals := range via[len(via)-1].Header {
+        if _, exists := req.Header[name]; !exists {
+            req.Header[name] = vals
+        }
+    }
+
+    return nil
+}
+
+func (p URLPolicy) Apply(c *colly.Collector) {
+    // REQUIRES:    c
+    // MODIFIES:    c
+    // EFFECTS:     Restricts the collector's body size and redirect limit.
+    //              The addresses are restricted by p.transport(), which the
+    //              collector has to use as well
+
+    c.MaxBodySize = p.MaxBodySize
+    c.RedirectHandler = p.redirectHandler
+}
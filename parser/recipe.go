@@ -0,0 +1,251 @@
+package parser
+
+import (
+    "log"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "logit/models"
+)
+
+var stepSplitExp = regexp.MustCompile(`(?i)\s*(?:\r?\n)+\s*|\s*<br\s*/?>\s*`)
+
+func NormalizeRecipe(raw map[string]interface{}) models.Recipe {
+    // REQUIRES:    raw
+    // MODIFIES:    raw
+    // EFFECTS:     Converts the raw recipe schema (ld+json, microdata or
+    //              heuristic) into a models.Recipe, coercing every field into
+    //              its typed form regardless of how the site chose to write it.
+    //              The text is sanitized first, see SanitizeSchema
+
+    var recipe models.Recipe
+    if raw == nil {
+        return recipe
+    }
+
+    SanitizeSchema(raw, "")
+
+    recipe.Name = toString(raw["name"])
+    recipe.Description = toString(raw["description"])
+    recipe.CookTime = toString(raw["cookTime"])
+    recipe.PrepTime = toString(raw["prepTime"])
+    recipe.TotalTime = toString(raw["totalTime"])
+    recipe.DatePublished = toString(raw["datePublished"])
+    recipe.Times = NormalizeTimes(recipe.PrepTime, recipe.CookTime, recipe.TotalTime)
+
+    recipe.Ingredients = toStringList(raw["recipeIngredient"], false)
+    recipe.Instructions = NormalizeInstructions(raw["recipeInstructions"])
+    recipe.Yield = toStringList(raw["recipeYield"], false)
+    recipe.Category = toStringList(raw["recipeCategory"], true)
+    recipe.Cuisine = toStringList(raw["recipeCuisine"], true)
+    recipe.Keywords = toStringList(raw["keywords"], true)
+    recipe.Author = NormalizeAuthor(raw["author"])
+    recipe.AggregateRating = NormalizeAggregateRating(raw["aggregateRating"])
+
+    // Normalize nutrition, image, and main entity data
+    recipe.Nutrition = NormalizeNutritionData(raw["nutrition"])
+    recipe.Images = NormalizeImageData(raw["image"])
+    if len(recipe.Images) > 0 {
+        recipe.Image = recipe.Images[0].Url
+    }
+    recipe.MainEntity = NormalizeMainEntity(raw["mainEntityOfPage"])
+
+    // needs both the yield and the nutrition
+    NormalizeServings(&recipe)
+
+    return recipe
+}
+
+func NormalizeInstructions(instructions interface{}) []models.InstructionStep {
+    // REQUIRES:    instructions
+    // MODIFIES:    none
+    // EFFECTS:     Flattens recipeInstructions into an ordered list of steps.
+    //              Sites use a single block of text, a list of strings, a list
+    //              of HowToStep or HowToSections that contain HowToSteps
+
+    var steps []models.InstructionStep
+    flattenInstructions(instructions, "", &steps)
+
+    for i := range steps {
+        steps[i].Position = i + 1
+    }
+
+    return steps
+}
+
+func flattenInstructions(node interface{}, section string, steps *[]models.InstructionStep) {
+    switch node := node.(type) {
+    case string:
+        for _, text := range stepSplitExp.Split(node, -1) {
+            if text = strings.TrimSpace(text); text != "" {
+                *steps = append(*steps, models.InstructionStep{Section: section, Text: text})
+            }
+        }
+    case []interface{}:
+        for _, child := range node {
+            flattenInstructions(child, section, steps)
+        }
+    case map[string]interface{}:
+        name := toString(node["name"])
+
+        // HowToSection and ItemList group steps under itemListElement
+        if children, exists := node["itemListElement"]; exists {
+            if hasSchemaType(node["@type"], "howtosection") && name != "" {
+                section = name
+            }
+            flattenInstructions(children, section, steps)
+            return
+        }
+
+        text := toString(node["text"])
+        if text == "" {
+            text = name
+        }
+        if text == "" {
+            return
+        }
+
+        // plenty of sites repeat the text as the name, or truncate it
+        if strings.HasPrefix(text, strings.TrimSuffix(name, "...")) {
+            name = ""
+        }
+
+        *steps = append(*steps, models.InstructionStep{
+            Section:    section,
+            Name:       name,
+            Text:       strings.TrimSpace(text),
+            Url:        toString(node["url"]),
+        })
+    case nil:
+    default:
+        log.Printf("[PARSER] instruction normalization: encountered type %T\n", node)
+    }
+}
+
+func NormalizeAuthor(author interface{}) []models.Author {
+    // REQUIRES:    author
+    // MODIFIES:    none
+    // EFFECTS:     Converts a Person, Organization, plain name or a list of
+    //              those into a list of authors
+
+    var authors []models.Author
+
+    switch author := author.(type) {
+    case string:
+        if author = strings.TrimSpace(author); author != "" {
+            authors = append(authors, models.Author{Name: author})
+        }
+    case map[string]interface{}:
+        name := toString(author["name"])
+        if name != "" {
+            authors = append(authors, models.Author{Name: name, Url: toString(author["url"])})
+        }
+    case []interface{}:
+        for _, a := range author {
+            authors = append(authors, NormalizeAuthor(a)...)
+        }
+    case nil:
+    default:
+        log.Printf("[PARSER] author normalization: encountered type %T\n", author)
+    }
+
+    return authors
+}
+
+func NormalizeAggregateRating(rating interface{}) *models.AggregateRating {
+    // REQUIRES:    rating
+    // MODIFIES:    none
+    // EFFECTS:     Reads an AggregateRating whose values may be numbers or
+    //              strings. Returns nil when the page has no rating
+
+    r, ok := rating.(map[string]interface{})
+    if !ok {
+        return nil
+    }
+
+    return &models.AggregateRating{
+        RatingValue:    toFloat(r["ratingValue"]),
+        RatingCount:    int(toFloat(r["ratingCount"])),
+        ReviewCount:    int(toFloat(r["reviewCount"])),
+        BestRating:     toFloat(r["bestRating"]),
+        WorstRating:    toFloat(r["worstRating"]),
+    }
+}
+
+func hasSchemaType(schemaType interface{}, want string) bool {
+    switch t := schemaType.(type) {
+    case string:
+        return strings.ToLower(t) == want
+    case []interface{}:
+        for _, val := range t {
+            if val, ok := val.(string); ok && strings.ToLower(val) == want {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+func toString(val interface{}) string {
+    switch val := val.(type) {
+    case string:
+        return strings.TrimSpace(val)
+    case float64:
+        return strconv.FormatFloat(val, 'f', -1, 64)
+    case []interface{}:
+        // some sites wrap single values in a list
+        if len(val) > 0 {
+            return toString(val[0])
+        }
+    case map[string]interface{}:
+        // e.g. {"@type": "Thing", "name": "Dessert"} or {"@id": "..."}
+        return firstNonEmpty(toString(val["name"]), toString(val["@id"]))
+    }
+
+    return ""
+}
+
+func toStringList(val interface{}, splitCommas bool) []string {
+    var list []string
+
+    switch val := val.(type) {
+    case []interface{}:
+        for _, v := range val {
+            list = append(list, toStringList(v, splitCommas)...)
+        }
+    default:
+        s := toString(val)
+        if s == "" {
+            break
+        }
+
+        if !splitCommas {
+            list = append(list, s)
+            break
+        }
+
+        for _, part := range strings.Split(s, ",") {
+            if part = strings.TrimSpace(part); part != "" {
+                list = append(list, part)
+            }
+        }
+    }
+
+    return list
+}
+
+func toFloat(val interface{}) float64 {
+    switch val := val.(type) {
+    case float64:
+        return val
+    case string:
+        f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+        if err == nil {
+            return f
+        }
+    }
+
+    return 0
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+    "html"
+    "regexp"
+    "strings"
+)
+
+var (
+    entityExp = regexp.MustCompile(`&(#\d+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+    commentExp = regexp.MustCompile(`(?s)<!--.*?-->`)
+    // a letter or slash has to follow, so "<1 g" isn't a tag
+    tagExp = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+    // tags that end a line in a block of text
+    lineBreakExp = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|h[1-6])>`)
+    // "1½" needs a space before the fraction once it's "1/2"
+    mixedFractionExp = regexp.MustCompile(`(\d)([¼½¾⅐⅑⅒⅓⅔⅕⅖⅗⅘⅙⅚⅛⅜⅝⅞↉])`)
+)
+
+var textReplacer = strings.NewReplacer(
+    // vulgar fractions
+    "¼", "1/4", "½", "1/2", "¾", "3/4", "⅐", "1/7", "⅑", "1/9", "⅒", "1/10",
+    "⅓", "1/3", "⅔", "2/3", "⅕", "1/5", "⅖", "2/5", "⅗", "3/5", "⅘", "4/5",
+    "⅙", "1/6", "⅚", "5/6", "⅛", "1/8", "⅜", "3/8", "⅝", "5/8", "⅞", "7/8",
+    "↉", "0/3",
+    // fraction slash, e.g. "1⁄2"
+    "⁄", "/",
+    // invisible characters that strings.Fields doesn't treat as space
+    "\u200b", "", "\u200c", "", "\u200d", "", "\u2060", "", "\ufeff", "", "\u00ad", "",
+)
+
+func SanitizeText(text string) string {
+    // REQUIRES:    text
+    // MODIFIES:    none
+    // EFFECTS:     Returns [text] as plain text on a single line. Decodes HTML
+    //              entities (also when they were encoded twice), strips tags,
+    //              turns vulgar fractions like "½" into "1/2" and collapses
+    //              non-breaking and other whitespace into single spaces
+
+    return strings.Join(strings.Fields(cleanText(text)), " ")
+}
+
+func sanitizeLines(text string) string {
+    // like SanitizeText, but keeps the text's lines (and the lines <br> and
+    // <p> tags stand for) apart, dropping the empty ones
+    text = lineBreakExp.ReplaceAllString(decodeEntities(text), "\n")
+
+    var lines []string
+    for _, line := range strings.Split(cleanText(text), "\n") {
+        if line = strings.Join(strings.Fields(line), " "); line != "" {
+            lines = append(lines, line)
+        }
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+func decodeEntities(text string) string {
+    // "&amp;#8217;" takes two rounds
+    for i := 0; i < 2 && entityExp.MatchString(text); i++ {
+        text = html.UnescapeString(text)
+    }
+
+    return text
+}
+
+func cleanText(text string) string {
+    text = decodeEntities(text)
+    text = commentExp.ReplaceAllString(text, " ")
+    text = tagExp.ReplaceAllString(text, " ")
+    text = mixedFractionExp.ReplaceAllString(text, "$1 $2")
+
+    return textReplacer.Replace(text)
+}
+
+func SanitizeSchema(val interface{}, key string) interface{} {
+    // REQUIRES:    val from FindRecipe or the other extractors
+    // MODIFIES:    val
+    // EFFECTS:     Runs every string in the recipe schema through
+    //              SanitizeText, so every text field of models.Recipe is
+    //              clean before the normalizers parse it. Instructions given
+    //              as a single string keep their lines, those are the steps
+
+    switch val := val.(type) {
+    case string:
+        if key == "recipeInstructions" {
+            return sanitizeLines(val)
+        }
+        return SanitizeText(val)
+    case map[string]interface{}:
+        for k, v := range val {
+            val[k] = SanitizeSchema(v, k)
+        }
+        return val
+    case []interface{}:
+        for i, v := range val {
+            val[i] = SanitizeSchema(v, key)
+        }
+        return val
+    case []string:
+        for i, v := range val {
+            val[i] = SanitizeSchema(v, key).(string)
+        }
+        return val
+    }
+
+    return val
+}
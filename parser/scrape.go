@@ -0,0 +1,348 @@
+package parser
+
+import (
+    "log"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/gocolly/colly"
+    "github.com/google/uuid"
+
+    "logit/models"
+)
+
+// pages that didn't give us a recipe are saved here as <id>.html so they
+// can be turned into a test fixture with cmd/fixture. Off unless
+// SCRAPE_FAILURE_LOG_DIR is set, the pages are whatever users submit
+var FailureLogDir = os.Getenv("SCRAPE_FAILURE_LOG_DIR")
+
+// everything a single page visit produced
+type scrapeResult struct {
+    // the stale cache entry being revalidated, if any
+    cached          *models.CachedRecipe
+    raw             map[string]interface{}
+    // every recipe schema in the ld+json, raw is recipes[rawIndex] if any
+    recipes         []map[string]interface{}
+    rawIndex        int
+    id              string
+    lowConfidence   bool
+    url             string
+    body            []byte
+    canonical       string
+    etag            string
+    lastModified    string
+    notModified     bool
+    malformedJSON   bool
+    status          int
+    err             error
+}
+
+func newCollector(uagents []string, options ...func(*colly.Collector)) *colly.Collector {
+    // REQUIRES:    uagents
+    // MODIFIES:    none
+    // EFFECTS:     Returns a collector following the URL and crawl policies,
+    //              with the recipe handlers registered
+
+    c := colly.NewCollector(options...)
+    Policy.Apply(c)
+    Crawl.Apply(c, uagents)
+    transport := Crawl.transport(Policy.transport(), Policy.Timeout)
+    c.WithTransport(transport)
+    // the transport times out each request instead
+    c.SetRequestTimeout(0)
+
+    c.OnRequest(robotsHandler(c, transport))
+    c.OnRequest(requestHandler())
+    c.OnResponse(responseHandler())
+    c.OnError(errorHandler())
+    c.OnScraped(scrapedHandler())
+    c.OnHTML("script[type='application/ld+json']", htmlHandler())
+    c.OnHTML("html", markupHandler())
+    c.OnHTML("html", heuristicHandler())
+    c.OnHTML("html", canonicalHandler())
+    // needs the canonical URL and whatever the generic handlers found
+    c.OnHTML("html", siteHandler())
+
+    return c
+}
+
+func visit(c *colly.Collector, link string, cached *models.CachedRecipe) *scrapeResult {
+    // REQUIRES:    c from newCollector, link
+    // MODIFIES:    c
+    // EFFECTS:     Queues [link] on [c] and returns the result the handlers
+    //              fill in. If [cached] is given, the request is made
+    //              conditional so the site can answer with 304 Not Modified.
+    //              On an async collector the result is only complete after
+    //              c.Wait()
+
+    result := &scrapeResult{cached: cached}
+
+    ctx := colly.NewContext()
+    ctx.Put(RESULT_KEY, result)
+
+    if err := c.Request("GET", link, nil, ctx, nil); err != nil && result.err == nil && !result.notModified {
+        result.err = err
+    }
+
+    return result
+}
+
+func scrape(link string, uagents []string, cached *models.CachedRecipe) *scrapeResult {
+    // REQUIRES:    link, uagents
+    // MODIFIES:    none
+    // EFFECTS:     Visits [link] and collects the recipe schema from it
+
+    return visit(newCollector(uagents), link, cached)
+}
+
+func lookupRecipe(link string) (string, *models.CachedRecipe, error) {
+    // REQUIRES:    link
+    // MODIFIES:    none
+    // EFFECTS:     Checks [link] against the URL policy and returns its cache
+    //              key along with whatever is cached under it
+
+    if _, err := Policy.ValidateURL(link); err != nil {
+        return "", nil, invalidURLError(err)
+    }
+
+    key, err := CanonicalizeURL(link)
+    if err != nil {
+        return "", nil, invalidURLError(err)
+    }
+
+    cached, err := Cache.Get(key)
+    if err != nil {
+        log.Printf("[PARSER] cache error: %+v\n", err)
+    }
+
+    return key, cached, nil
+}
+
+func FetchRecipe(link string, uagents []string) (models.Recipe, error) {
+    // REQUIRES:    link, uagents
+    // MODIFIES:    Cache
+    // EFFECTS:     Returns the normalized recipe at [link]. Fresh cache entries
+    //              are returned as is, stale ones are revalidated with the
+    //              site, and everything else is scraped and cached under the
+    //              requested URL as well as the page's canonical URLs on the
+    //              same host. Errors are *ScrapeError. A PARTIAL_RECIPE error
+    //              still comes with the recipe
+
+    key, cached, err := lookupRecipe(link)
+    if err != nil {
+        return models.Recipe{}, err
+    }
+
+    if IsFresh(cached) {
+        log.Printf("[PARSER] cache hit: %s\n", key)
+        return cached.Recipe, partialRecipeError(cached.Recipe)
+    }
+
+    return finishRecipe(key, scrape(link, uagents, cached))
+}
+
+func RefetchRecipe(link string, uagents []string) (models.Recipe, error) {
+    // REQUIRES:    link, uagents
+    // MODIFIES:    Cache
+    // EFFECTS:     Like FetchRecipe, but asks the site even when the cached
+    //              recipe is still fresh, to see whether the recipe changed.
+    //              The request is still conditional, an unchanged page only
+    //              costs a 304
+
+    key, cached, err := lookupRecipe(link)
+    if err != nil {
+        return models.Recipe{}, err
+    }
+
+    return finishRecipe(key, scrape(link, uagents, cached))
+}
+
+func FetchAllRecipes(link string, uagents []string) ([]models.Recipe, error) {
+    // REQUIRES:    link, uagents
+    // MODIFIES:    Cache
+    // EFFECTS:     Like FetchRecipe, but returns every recipe on the page in
+    //              the order the page lists them, e.g. for roundups or a main
+    //              dish with a sauce. Which of them are incomplete isn't
+    //              reported, see SelectRecipe
+
+    key, cached, err := lookupRecipe(link)
+    if err != nil {
+        return nil, err
+    }
+
+    // entries cached before all recipes were kept have to be scraped again
+    if cached != nil && cached.Recipes == nil {
+        cached = nil
+    }
+
+    if IsFresh(cached) {
+        log.Printf("[PARSER] cache hit: %s\n", key)
+        return append([]models.Recipe(nil), cached.Recipes...), nil
+    }
+
+    entry, err := finishVisit(key, scrape(link, uagents, cached))
+    if err != nil {
+        return nil, err
+    }
+
+    // the slice is shared with the cache, the caller may rescale the recipes
+    return append([]models.Recipe(nil), entry.Recipes...), nil
+}
+
+func SelectRecipe(recipes []models.Recipe, index *int, name string) (models.Recipe, error) {
+    // REQUIRES:    recipes from FetchAllRecipes
+    // MODIFIES:    none
+    // EFFECTS:     Returns the recipe at [index], or else the one called
+    //              [name], ignoring case. An exact name wins over one that
+    //              only contains [name]. Errors like FetchRecipe
+
+    if index != nil {
+        if *index < 0 || *index >= len(recipes) {
+            return models.Recipe{}, recipeNotFoundError(len(recipes))
+        }
+        return recipes[*index], partialRecipeError(recipes[*index])
+    }
+
+    name = strings.ToLower(strings.TrimSpace(name))
+    match := -1
+    for i, recipe := range recipes {
+        recipeName := strings.ToLower(recipe.Name)
+        if recipeName == name {
+            match = i
+            break
+        }
+        if match == -1 && name != "" && strings.Contains(recipeName, name) {
+            match = i
+        }
+    }
+
+    if match == -1 {
+        return models.Recipe{}, recipeNotFoundError(len(recipes))
+    }
+
+    return recipes[match], partialRecipeError(recipes[match])
+}
+
+func finishRecipe(key string, result *scrapeResult) (models.Recipe, error) {
+    // REQUIRES:    key, result of a finished visit
+    // MODIFIES:    Cache
+    // EFFECTS:     Turns what the visit collected into a normalized recipe,
+    //              or the error explaining why there is none, and caches it
+
+    entry, err := finishVisit(key, result)
+    if err != nil {
+        return models.Recipe{}, err
+    }
+
+    return entry.Recipe, partialRecipeError(entry.Recipe)
+}
+
+func finishVisit(key string, result *scrapeResult) (models.CachedRecipe, error) {
+    // REQUIRES:    key, result of a finished visit
+    // MODIFIES:    Cache
+    // EFFECTS:     Normalizes what the visit collected and caches it, or
+    //              returns the *ScrapeError explaining why there's no recipe.
+    //              A revalidated entry is returned as it was cached
+
+    if result.notModified && result.cached != nil {
+        log.Printf("[PARSER] cache revalidated: %s\n", key)
+        cached := *result.cached
+        cached.FetchedAt = time.Now()
+        storeRecipe(cached, key)
+        return cached, nil
+    }
+
+    if result.err != nil {
+        return models.CachedRecipe{}, fetchError(result.err, result.status)
+    }
+
+    if len(result.raw) == 0 {
+        logFailure(result)
+        return models.CachedRecipe{}, noRecipeError(result.malformedJSON)
+    }
+
+    recipe := NormalizeRecipe(result.raw)
+    recipe.LowConfidence = result.lowConfidence
+    ResolveImages(&recipe, result.url)
+
+    // the others are normalized the same way, the site extractors only fix
+    // the one in raw
+    recipes := []models.Recipe{recipe}
+    if len(result.recipes) > 0 {
+        recipes = make([]models.Recipe, len(result.recipes))
+        for i, raw := range result.recipes {
+            if i == result.rawIndex {
+                recipes[i] = recipe
+                continue
+            }
+
+            recipes[i] = NormalizeRecipe(raw)
+            ResolveImages(&recipes[i], result.url)
+        }
+    }
+
+    entry := models.CachedRecipe{
+        Url:            result.url,
+        Recipe:         recipe,
+        Recipes:        recipes,
+        ETag:           result.etag,
+        LastModified:   result.lastModified,
+        FetchedAt:      time.Now(),
+    }
+
+    // the page says what its canonical URLs are, so they're only trusted on
+    // the host it was served from. Otherwise any page could put its recipe
+    // in the cache for someone else's URL
+    mainEntity, _ := recipe.MainEntity.(string)
+    storeRecipe(entry, key, result.url,
+        sameHostURL(result.url, result.canonical), sameHostURL(result.url, mainEntity))
+
+    return entry, nil
+}
+
+func storeRecipe(entry models.CachedRecipe, keys ...string) {
+    for _, key := range cacheKeys(keys...) {
+        if err := Cache.Set(key, entry, CACHE_EXPIRY); err != nil {
+            log.Printf("[PARSER] cache error: %+v\n", err)
+        }
+    }
+}
+
+func sameHostURL(page, link string) string {
+    // [link] if it's on the same host as [page], otherwise empty
+    if link == "" {
+        return ""
+    }
+
+    pageURL, err := url.Parse(page)
+    if err != nil {
+        return ""
+    }
+    linkURL, err := url.Parse(link)
+    if err != nil || (linkURL.Scheme != "http" && linkURL.Scheme != "https") {
+        return ""
+    }
+    if !strings.EqualFold(pageURL.Hostname(), linkURL.Hostname()) {
+        return ""
+    }
+
+    return link
+}
+
+func logFailure(result *scrapeResult) {
+    if FailureLogDir == "" || len(result.body) == 0 {
+        return
+    }
+
+    result.id = uuid.New().String()
+    path := filepath.Join(FailureLogDir, result.id+".html")
+    if err := os.WriteFile(path, result.body, 0644); err != nil {
+        log.Printf("[PARSER] couldn't save failed page: %+v\n", err)
+        return
+    }
+
+    log.Printf("[PARSER] no recipe on %s, saved page as %s\n", result.url, result.id)
+}
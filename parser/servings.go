@@ -0,0 +1,178 @@
+package parser
+
+import (
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "logit/models"
+)
+
+// "4", "4 servings", "Serves 4-6", "Makes 2.5 dozen"
+var servingsExp = regexp.MustCompile(`(\d+(?:[.,]\d+)?)(?:\s*(?:-|–|to)\s*\d+(?:[.,]\d+)?)?`)
+
+// "1/8 of recipe" or "1/8 pie"
+var servingFractionExp = regexp.MustCompile(`^\s*1\s*/\s*(\d+)\b`)
+
+// yields that explicitly talk about portions win over "Makes 1 loaf"
+var servingWordsExp = regexp.MustCompile(`(?i)\b(servings?|serves|people|persons?|portions?)\b`)
+
+// servingSize values meaning the nutrition covers the whole recipe
+var wholeRecipeExp = regexp.MustCompile(`(?i)\b(whole|entire|total|full)\s+(recipe|batch|dish)\b|^\s*1\s+(recipe|batch)\b`)
+
+func ParseServings(yield []string, servingSize string) float64 {
+    // REQUIRES:    yield, servingSize
+    // MODIFIES:    none
+    // EFFECTS:     Reads the number of servings the recipe makes from the
+    //              recipeYield values, preferring the ones that mention
+    //              servings. Falls back to a servingSize like "1/8 of recipe".
+    //              Returns 0 when the recipe doesn't say
+
+    var fallback float64
+    for _, val := range yield {
+        servings := parseServingCount(val)
+        if servings <= 0 {
+            continue
+        }
+
+        if servingWordsExp.MatchString(val) {
+            return servings
+        }
+        if fallback == 0 {
+            fallback = servings
+        }
+    }
+
+    if fallback > 0 {
+        return fallback
+    }
+
+    if match := servingFractionExp.FindStringSubmatch(servingSize); match != nil {
+        servings, _ := strconv.ParseFloat(match[1], 64)
+        return servings
+    }
+
+    return 0
+}
+
+func parseServingCount(val string) float64 {
+    match := servingsExp.FindStringSubmatch(val)
+    if match == nil {
+        return 0
+    }
+
+    // a range such as "4-6 servings" uses the lower bound
+    servings, err := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+    if err != nil {
+        return 0
+    }
+
+    if strings.Contains(strings.ToLower(val), "dozen") {
+        servings *= 12
+    }
+
+    return servings
+}
+
+func ScaleNutrition(info *models.NutritionInfo, factor float64) *models.NutritionInfo {
+    // REQUIRES:    factor > 0
+    // MODIFIES:    none
+    // EFFECTS:     Returns a copy of [info] with every nutrient multiplied by
+    //              [factor]
+
+    if info == nil {
+        return nil
+    }
+
+    scaled := &models.NutritionInfo{
+        ServingSize:    info.ServingSize,
+        Servings:       info.Servings * factor,
+        Nutrients:      make(map[string]models.Nutrient, len(info.Nutrients)),
+    }
+
+    for key, nutrient := range info.Nutrients {
+        nutrient.Qty = math.Round(nutrient.Qty*factor*1e4) / 1e4
+        scaled.Nutrients[key] = nutrient
+    }
+
+    scaled.Summary = SummarizeNutrients(scaled.Nutrients)
+    return scaled
+}
+
+func NormalizeServings(recipe *models.Recipe) {
+    // REQUIRES:    recipe with its Nutrition and Yield normalized
+    // MODIFIES:    recipe
+    // EFFECTS:     Works out how many servings the recipe makes and fills in
+    //              both the per serving and the whole recipe nutrition. Sites
+    //              are supposed to publish per serving values, so that is
+    //              assumed unless servingSize says it covers the whole recipe
+
+    servingSize := ""
+    if recipe.Nutrition != nil {
+        servingSize = recipe.Nutrition.ServingSize
+    }
+
+    servings := ParseServings(recipe.Yield, servingSize)
+    if servings <= 0 {
+        servings = 1
+    }
+    recipe.Servings = servings
+
+    if recipe.Nutrition == nil {
+        return
+    }
+
+    if wholeRecipeExp.MatchString(servingSize) {
+        recipe.TotalNutrition = recipe.Nutrition
+        recipe.TotalNutrition.Servings = servings
+        recipe.Nutrition = ScaleNutrition(recipe.TotalNutrition, 1/servings)
+        recipe.Nutrition.ServingSize = ""
+    } else {
+        recipe.Nutrition.Servings = 1
+        recipe.TotalNutrition = ScaleNutrition(recipe.Nutrition, servings)
+        recipe.TotalNutrition.ServingSize = ""
+    }
+}
+
+func RescaleServings(recipe *models.Recipe, servings float64) {
+    // REQUIRES:    servings > 0, recipe normalized with NormalizeServings
+    // MODIFIES:    recipe
+    // EFFECTS:     Divides the whole recipe into [servings] servings instead
+    //              of what the site said, so the per serving nutrition matches
+    //              what the user actually eats. The recipe total is unchanged
+
+    recipe.Servings = servings
+    if recipe.TotalNutrition == nil {
+        return
+    }
+
+    // copy instead of modifying in place, the nutrition may be shared with
+    // a cached recipe
+    total := ScaleNutrition(recipe.TotalNutrition, 1)
+    total.Servings = servings
+
+    recipe.TotalNutrition = total
+    recipe.Nutrition = ScaleNutrition(total, 1/servings)
+    recipe.Nutrition.ServingSize = ""
+}
+
+func SetTotalNutrition(recipe *models.Recipe, total models.Nutrition) {
+    // REQUIRES:    recipe normalized with NormalizeServings, total is what
+    //              the whole recipe adds up to
+    // MODIFIES:    recipe
+    // EFFECTS:     Fills in the nutrition of a recipe that didn't come with
+    //              any, e.g. from the recipe builder adding up its
+    //              ingredients, divided into recipe.Servings servings
+
+    if recipe.Servings <= 0 {
+        recipe.Servings = 1
+    }
+
+    recipe.TotalNutrition = &models.NutritionInfo{
+        Servings:   recipe.Servings,
+        Nutrients:  nutrientsOf(total),
+    }
+    recipe.TotalNutrition.Summary = SummarizeNutrients(recipe.TotalNutrition.Nutrients)
+    recipe.Nutrition = ScaleNutrition(recipe.TotalNutrition, 1/recipe.Servings)
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+    "net/url"
+    "strings"
+    "sync"
+
+    "github.com/PuerkitoBio/goquery"
+    "github.com/gocolly/colly"
+    "github.com/google/uuid"
+)
+
+// SiteExtractor fixes the recipe schema of a site whose structured data is
+// broken, without touching the generic path every other site goes through
+type SiteExtractor interface {
+    // Extract is given the page and the recipe schema FindRecipe, the markup
+    // or the heuristics found on it (nil if none) and returns the schema to
+    // normalize instead. It may modify [raw] in place and return it
+    Extract(page *goquery.Selection, raw map[string]interface{}) map[string]interface{}
+}
+
+// extractors by host without "www.", subdomains use the extractor of their
+// parent domain unless they have their own
+var (
+    siteExtractors = map[string]SiteExtractor{
+        "budgetbytes.com":  wprmExtractor{},
+        "pinchofyum.com":   ingredientHeadingsExtractor{},
+    }
+    siteExtractorsLock sync.RWMutex
+)
+
+func RegisterSiteExtractor(host string, extractor SiteExtractor) {
+    siteExtractorsLock.Lock()
+    defer siteExtractorsLock.Unlock()
+
+    siteExtractors[strings.TrimPrefix(strings.ToLower(host), "www.")] = extractor
+}
+
+func SiteExtractorFor(host string) SiteExtractor {
+    // REQUIRES:    host
+    // MODIFIES:    none
+    // EFFECTS:     Returns the extractor registered for [host] or its closest
+    //              parent domain, or nil if the site uses the generic path
+
+    host = strings.TrimPrefix(strings.ToLower(host), "www.")
+
+    siteExtractorsLock.RLock()
+    defer siteExtractorsLock.RUnlock()
+
+    for host != "" {
+        if extractor, exists := siteExtractors[host]; exists {
+            return extractor
+        }
+
+        dot := strings.Index(host, ".")
+        if dot == -1 {
+            break
+        }
+        host = host[dot+1:]
+    }
+
+    return nil
+}
+
+func siteHandler() colly.HTMLCallback {
+    return func(h *colly.HTMLElement) {
+        // runs after the generic handlers. The page's canonical URL is used
+        // when the host it was served from has no extractor, e.g. for AMP
+        // pages and mirrors
+        result := resultOf(h.Request)
+
+        extractor := SiteExtractorFor(h.Request.URL.Hostname())
+        if extractor == nil && result.canonical != "" {
+            if u, err := url.Parse(result.canonical); err == nil {
+                extractor = SiteExtractorFor(u.Hostname())
+            }
+        }
+
+        if extractor == nil {
+            return
+        }
+
+        if raw := extractor.Extract(h.DOM, result.raw); len(raw) > 0 {
+            if len(result.raw) == 0 {
+                result.id = uuid.New().String()
+            }
+            result.raw = raw
+        }
+    }
+}
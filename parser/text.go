@@ -0,0 +1,172 @@
+package parser
+
+import (
+    "regexp"
+    "strings"
+
+    "logit/models"
+)
+
+// sections of a recipe pasted as plain text
+const (
+    TEXT_INTRO          = "intro"
+    TEXT_INGREDIENTS    = "ingredients"
+    TEXT_INSTRUCTIONS   = "instructions"
+    TEXT_NOTES          = "notes"
+)
+
+// headings that start a section, "Ingredients:", "Method", "You'll need"
+var textSectionExps = map[string]*regexp.Regexp{
+    TEXT_INGREDIENTS:   regexp.MustCompile(`(?i)^(ingredients?( list)?|what you('ll| will) need|you('ll| will) need|shopping list)\s*:?$`),
+    TEXT_INSTRUCTIONS:  regexp.MustCompile(`(?i)^(instructions?|directions?|method|steps|preparation|how to make( it)?)\s*:?$`),
+    TEXT_NOTES:         regexp.MustCompile(`(?i)^(notes?|tips?|variations?|storage)\s*:?$`),
+}
+
+var (
+    // "Serves 4", "Makes: 12 cookies", "Yield 1 loaf"
+    textYieldExp = regexp.MustCompile(`(?i)^(serves|servings|yields?|makes|portions)\s*:?\s*(.+)$`)
+    // "Prep time: 10 mins", "Bake: 1 hour". The colon keeps "Bake for an hour" a step
+    textTimeExp = regexp.MustCompile(`(?i)^(prep|preparation|cook|cooking|bake|baking|total)(\s+time)?\s*:\s*(.+)$`)
+    // "1.", "2)", "Step 3:", but not "1.5 cups"
+    textStepExp = regexp.MustCompile(`(?i)^(step\s*\d{1,2}\s*[.):-]?|\d{1,2}\s*[.)])\s+`)
+    textBulletExp = regexp.MustCompile(`^[-*•·–—▢☐□]\s*`)
+    textQuantityExp = regexp.MustCompile(`^\d`)
+)
+
+// schema.org keys for the times in textTimeExp
+var textTimeKeys = map[string]string{
+    "prep": "prepTime", "preparation": "prepTime",
+    "cook": "cookTime", "cooking": "cookTime", "bake": "cookTime", "baking": "cookTime",
+    "total": "totalTime",
+}
+
+// ingredients are short, anything longer without a heading is a step
+const MAX_INGREDIENT_WORDS = 12
+
+func SegmentRecipeText(text string) map[string]interface{} {
+    // REQUIRES:    text
+    // MODIFIES:    none
+    // EFFECTS:     Splits a recipe pasted as plain text into the recipe schema
+    //              NormalizeRecipe takes. Headings like "Ingredients" and
+    //              "Directions" decide the sections when there are any.
+    //              Otherwise the first line is the name, lines starting with
+    //              an amount or a bullet are ingredients and numbered lines or
+    //              sentences are steps. "Serves 4" and "Prep time: 10 mins"
+    //              are picked up anywhere. Returns nil if there are no
+    //              ingredients
+
+    raw := map[string]interface{}{"@type": "Recipe"}
+
+    var description, ingredients, instructions []interface{}
+    section := TEXT_INTRO
+    // whether the section was started by a heading instead of a guess
+    headed := false
+    // numbered steps can wrap onto lines of their own
+    numbered := false
+
+    for _, line := range strings.Split(text, "\n") {
+        line = SanitizeText(line)
+        if line == "" {
+            continue
+        }
+
+        if heading := textSection(line); heading != "" {
+            section, headed = heading, true
+            continue
+        }
+
+        if match := textYieldExp.FindStringSubmatch(line); match != nil && len(strings.Fields(line)) <= 6 {
+            raw["recipeYield"] = match[2]
+            continue
+        }
+
+        if match := textTimeExp.FindStringSubmatch(line); match != nil {
+            raw[textTimeKeys[strings.ToLower(match[1])]] = match[3]
+            continue
+        }
+
+        isStep := textStepExp.MatchString(line)
+        isIngredient := !isStep && (textBulletExp.MatchString(line) || textQuantityExp.MatchString(line))
+
+        // the first ingredient ends the intro and the first step ends the
+        // ingredients, a sentence only does without a heading
+        switch {
+        case section == TEXT_INTRO && isIngredient:
+            section = TEXT_INGREDIENTS
+        case section == TEXT_INGREDIENTS && (isStep || (!headed && looksLikeSentence(line))):
+            section, headed = TEXT_INSTRUCTIONS, false
+        }
+
+        switch section {
+        case TEXT_INTRO:
+            if _, exists := raw["name"]; !exists {
+                raw["name"] = line
+            } else {
+                description = append(description, line)
+            }
+        case TEXT_INGREDIENTS:
+            line = textBulletExp.ReplaceAllString(line, "")
+            if line != "" && !isIngredientHeading(line) {
+                ingredients = append(ingredients, line)
+            }
+        case TEXT_INSTRUCTIONS:
+            step := textStepExp.ReplaceAllString(textBulletExp.ReplaceAllString(line, ""), "")
+            if isStep {
+                numbered = true
+            } else if numbered && len(instructions) > 0 {
+                last := len(instructions) - 1
+                instructions[last] = instructions[last].(string) + " " + step
+                continue
+            }
+
+            if step != "" {
+                instructions = append(instructions, step)
+            }
+        }
+    }
+
+    if len(ingredients) == 0 {
+        return nil
+    }
+
+    raw["recipeIngredient"] = ingredients
+    if len(instructions) > 0 {
+        raw["recipeInstructions"] = instructions
+    }
+    if len(description) > 0 {
+        raw["description"] = strings.Join(toStringList(description, false), " ")
+    }
+
+    return raw
+}
+
+func ParseRecipeText(text string) (models.Recipe, bool) {
+    // REQUIRES:    text
+    // MODIFIES:    none
+    // EFFECTS:     Returns the recipe in [text], see SegmentRecipeText. It has
+    //              no nutrition until SetTotalNutrition is called with what
+    //              its ingredients add up to. Returns false if [text] has no
+    //              ingredients
+
+    raw := SegmentRecipeText(text)
+    if raw == nil {
+        return models.Recipe{}, false
+    }
+
+    return NormalizeRecipe(raw), true
+}
+
+func textSection(line string) string {
+    for section, exp := range textSectionExps {
+        if exp.MatchString(line) {
+            return section
+        }
+    }
+
+    return ""
+}
+
+func looksLikeSentence(line string) bool {
+    return len(strings.Fields(line)) > MAX_INGREDIENT_WORDS ||
+        (strings.HasSuffix(line, ".") && !textQuantityExp.MatchString(line) && !textBulletExp.MatchString(line))
+}
@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	// logit libs
+	"logit/models"
+
+	// redis client lib
+	goredis "github.com/go-redis/redis/v8"
+)
+
+const jobQueueKey = "jobs:queue"
+
+// JobStore implements jobs.JobStore on top of the shared client
+type JobStore struct{}
+
+// JobQueue implements jobs.JobQueue as a redis list, so jobs submitted by one
+// process can be run by the workers of another
+type JobQueue struct{}
+
+func jobKey(id string) string {
+    return "job:" + id
+}
+
+func (JobStore) Get(id string) (*models.Job, error) {
+    jobJSON, err := Client.Get(redisCtx, jobKey(id)).Result()
+    if err == goredis.Nil {
+        return nil, nil
+    } else if err != nil {
+        log.Printf("[REDIS] job store error: %+v", err)
+        return nil, err
+    }
+
+    var job models.Job
+    if err = json.Unmarshal([]byte(jobJSON), &job); err != nil {
+        log.Printf("[REDIS] unmarshal error: %+v", err)
+        return nil, err
+    }
+
+    return &job, nil
+}
+
+func (JobStore) Set(job models.Job, ttl time.Duration) error {
+    json, err := json.Marshal(job)
+    if err != nil {
+        log.Printf("[REDIS] marshal error: %+v", err)
+        return err
+    }
+
+    err = Client.Set(redisCtx, jobKey(job.Id), json, ttl).Err()
+    if err != nil {
+        log.Printf("[REDIS] error: %+v", err)
+        return err
+    }
+
+    return nil
+}
+
+func (JobQueue) Push(id string) error {
+    return Client.LPush(redisCtx, jobQueueKey, id).Err()
+}
+
+func (JobQueue) Pop() (string, error) {
+    // blocks until a job is pushed, the reply is [key, value]
+    reply, err := Client.BRPop(redisCtx, 0, jobQueueKey).Result()
+    if err != nil {
+        return "", err
+    }
+
+    return reply[1], nil
+}
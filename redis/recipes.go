@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	// logit libs
+	"logit/models"
+
+	// redis client lib
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// RecipeCache implements parser.RecipeCache on top of the shared client
+type RecipeCache struct{}
+
+func recipeKey(key string) string {
+    return fmt.Sprintf("recipe:%x", sha256.Sum256([]byte(key)))
+}
+
+func (RecipeCache) Get(key string) (*models.CachedRecipe, error) {
+    entryJSON, err := Client.Get(redisCtx, recipeKey(key)).Result()
+    if err == goredis.Nil {
+        return nil, nil
+    } else if err != nil {
+        log.Printf("[REDIS] recipe cache error: %+v", err)
+        return nil, err
+    }
+
+    var entry models.CachedRecipe
+    if err = json.Unmarshal([]byte(entryJSON), &entry); err != nil {
+        log.Printf("[REDIS] unmarshal error: %+v", err)
+        return nil, err
+    }
+
+    return &entry, nil
+}
+
+func (RecipeCache) Set(key string, entry models.CachedRecipe, ttl time.Duration) error {
+    json, err := json.Marshal(entry)
+    if err != nil {
+        log.Printf("[REDIS] marshal error: %+v", err)
+        return err
+    }
+
+    err = Client.Set(redisCtx, recipeKey(key), json, ttl).Err()
+    if err != nil {
+        log.Printf("[REDIS] error: %+v", err)
+        return err
+    }
+
+    return nil
+}
@@ -0,0 +1,239 @@
+package units
+
+import (
+    "strings"
+)
+
+// what a unit measures, units of a kind convert into each other
+const (
+    MASS    = "mass"
+    VOLUME  = "volume"
+    // cloves and slices weigh what the food's portion of them weighs
+    COUNT   = "count"
+    // inches don't convert without knowing the food
+    OTHER   = ""
+)
+
+type Unit struct {
+    // the name every spelling is normalized to
+    Name        string
+    Kind        string
+    // grams of a mass unit, milliliters of a volume unit
+    Size        float64
+    Spellings   []string
+}
+
+var table = []Unit{
+    {"mg", MASS, 0.001, []string{"mg", "milligram", "milligrams"}},
+    {"g", MASS, 1, []string{"g", "gr", "gram", "grams", "gramme", "grammes"}},
+    {"kg", MASS, 1000, []string{"kg", "kgs", "kilogram", "kilograms"}},
+    {"oz", MASS, 28.349523125, []string{"oz", "ounce", "ounces"}},
+    {"lb", MASS, 453.59237, []string{"lb", "lbs", "pound", "pounds"}},
+
+    {"ml", VOLUME, 1, []string{"ml", "milliliter", "milliliters", "millilitre", "millilitres"}},
+    {"cl", VOLUME, 10, []string{"cl", "centiliter", "centiliters", "centilitre", "centilitres"}},
+    {"dl", VOLUME, 100, []string{"dl", "deciliter", "deciliters", "decilitre", "decilitres"}},
+    {"l", VOLUME, 1000, []string{"l", "liter", "liters", "litre", "litres"}},
+    // US customary, what the USDA portions are measured in
+    {"pinch", VOLUME, 0.30805759960937, []string{"pinch", "pinches"}},
+    {"dash", VOLUME, 0.61611519921875, []string{"dash", "dashes"}},
+    {"tsp", VOLUME, 4.92892159375, []string{"tsp", "tsps", "teaspoon", "teaspoons"}},
+    {"tbsp", VOLUME, 14.78676478125, []string{"tbsp", "tbsps", "tbs", "tbl", "tblsp", "tablespoon", "tablespoons"}},
+    {"fl oz", VOLUME, 29.5735295625, []string{"fl oz", "fl. oz", "floz", "fluid ounce", "fluid ounces"}},
+    {"cup", VOLUME, 236.5882365, []string{"cup", "cups", "c"}},
+    {"pint", VOLUME, 473.176473, []string{"pint", "pints", "pt"}},
+    {"quart", VOLUME, 946.352946, []string{"quart", "quarts", "qt"}},
+    {"gallon", VOLUME, 3785.411784, []string{"gallon", "gallons", "gal"}},
+
+    {"inch", OTHER, 0, []string{"inch", "inches"}},
+    {"cm", OTHER, 0, []string{"cm", "centimeter", "centimeters", "centimetre", "centimetres"}},
+    {"handful", COUNT, 0, []string{"handful", "handfuls"}},
+    {"clove", COUNT, 0, []string{"clove", "cloves"}},
+    {"slice", COUNT, 0, []string{"slice", "slices"}},
+    {"piece", COUNT, 0, []string{"piece", "pieces"}},
+    {"stick", COUNT, 0, []string{"stick", "sticks"}},
+    {"sprig", COUNT, 0, []string{"sprig", "sprigs"}},
+    {"bunch", COUNT, 0, []string{"bunch", "bunches"}},
+    {"head", COUNT, 0, []string{"head", "heads"}},
+    {"stalk", COUNT, 0, []string{"stalk", "stalks"}},
+    {"can", COUNT, 0, []string{"can", "cans", "tin", "tins"}},
+    {"jar", COUNT, 0, []string{"jar", "jars"}},
+    {"package", COUNT, 0, []string{"package", "packages", "pkg", "packet", "packets"}},
+}
+
+// lower case spelling to its unit
+var bySpelling = indexSpellings(table)
+
+// abbreviations only their case tells apart, "1 T" is a tbsp and "1 t" a tsp
+var byExactSpelling = map[string]Unit{
+    "T": bySpelling["tbsp"],
+    "t": bySpelling["tsp"],
+}
+
+// how big a counted food is, "1 large egg". The USDA has portions by them
+var sizes = map[string]string{
+    "small": "small", "sm": "small",
+    "medium": "medium", "med": "medium",
+    "large": "large", "lg": "large",
+    "extra large": "extra large", "extra-large": "extra large", "x-large": "extra large", "xl": "extra large",
+    "jumbo": "jumbo",
+}
+
+func indexSpellings(units []Unit) map[string]Unit {
+    index := map[string]Unit{}
+    for _, unit := range units {
+        for _, spelling := range unit.Spellings {
+            index[spelling] = unit
+        }
+    }
+
+    return index
+}
+
+func Spellings() []string {
+    // REQUIRES:    none
+    // MODIFIES:    none
+    // EFFECTS:     Returns every spelling of every unit, e.g. for a pattern
+    //              that finds units in ingredient lines
+
+    spellings := make([]string, 0, len(bySpelling))
+    for spelling := range bySpelling {
+        spellings = append(spellings, spelling)
+    }
+
+    return spellings
+}
+
+func ExactSpellings() []string {
+    // REQUIRES:    none
+    // MODIFIES:    none
+    // EFFECTS:     Returns the spellings that are only units in their exact
+    //              case, "T" and "t". A pattern has to match them case
+    //              sensitively
+
+    spellings := make([]string, 0, len(byExactSpelling))
+    for spelling := range byExactSpelling {
+        spellings = append(spellings, spelling)
+    }
+
+    return spellings
+}
+
+func Lookup(unit string) (Unit, bool) {
+    // REQUIRES:    unit
+    // MODIFIES:    none
+    // EFFECTS:     Returns the unit [unit] is a spelling of. Case, dots,
+    //              spacing and plural endings don't matter, "Tbsp.",
+    //              "tablespoons" and "TBSPS" are all tbsp. Except for the
+    //              ExactSpellings, "T" is a tbsp but "t" a tsp
+
+    unit = strings.TrimSuffix(strings.Join(strings.Fields(unit), " "), ".")
+    if found, exists := byExactSpelling[unit]; exists {
+        return found, true
+    }
+
+    unit = strings.ToLower(unit)
+    if found, exists := bySpelling[unit]; exists {
+        return found, true
+    }
+
+    // plurals the table doesn't list
+    for _, suffix := range []string{"es", "s"} {
+        if found, exists := bySpelling[strings.TrimSuffix(unit, suffix)]; exists && strings.HasSuffix(unit, suffix) {
+            return found, true
+        }
+    }
+
+    return Unit{}, false
+}
+
+func Normalize(unit string) string {
+    // REQUIRES:    unit
+    // MODIFIES:    none
+    // EFFECTS:     Returns the name of the unit [unit] is a spelling of, or
+    //              [unit] in lower case if it isn't a known unit
+
+    if found, ok := Lookup(unit); ok {
+        return found.Name
+    }
+
+    return strings.ToLower(strings.TrimSpace(unit))
+}
+
+func Convert(value float64, from, to string) (float64, bool) {
+    // REQUIRES:    from, to
+    // MODIFIES:    none
+    // EFFECTS:     Returns [value] [from] in [to], false if they don't measure
+    //              the same thing, like cups and grams. Counts and unknown
+    //              units convert only into themselves
+
+    fromUnit, fromOk := Lookup(from)
+    toUnit, toOk := Lookup(to)
+    if !fromOk || !toOk {
+        if !fromOk && !toOk && Normalize(from) == Normalize(to) {
+            return value, true
+        }
+        return 0, false
+    }
+
+    if fromUnit.Name == toUnit.Name {
+        return value, true
+    }
+    if fromUnit.Kind == OTHER || fromUnit.Kind == COUNT || fromUnit.Kind != toUnit.Kind {
+        return 0, false
+    }
+
+    return value * fromUnit.Size / toUnit.Size, true
+}
+
+func ToGrams(value float64, unit string) (float64, bool) {
+    // REQUIRES:    unit
+    // MODIFIES:    none
+    // EFFECTS:     Returns [value] [unit] in grams, false if [unit] isn't a
+    //              mass unit. Volumes need the food's density, see
+    //              builder.PortionGrams
+
+    return Convert(value, unit, "g")
+}
+
+func SplitSize(name string) (string, string) {
+    // REQUIRES:    name of an ingredient
+    // MODIFIES:    none
+    // EFFECTS:     Returns the size [name] starts or ends with, "large" of
+    //              "large eggs" and "eggs, large", and the rest of the name.
+    //              The size is empty if it has none
+
+    words := strings.Fields(name)
+    // "extra large" before "large"
+    for n := 2; n >= 1; n-- {
+        if len(words) < n {
+            continue
+        }
+
+        if size, exists := sizes[strings.ToLower(strings.Join(words[:n], " "))]; exists {
+            return size, strings.Join(words[n:], " ")
+        }
+    }
+    for n := 2; n >= 1; n-- {
+        if len(words) <= n {
+            continue
+        }
+
+        last := strings.Join(words[len(words)-n:], " ")
+        if size, exists := sizes[strings.ToLower(last)]; exists {
+            return size, strings.TrimRight(strings.Join(words[:len(words)-n], " "), " ,")
+        }
+    }
+
+    return "", name
+}
+
+func IsVolume(unit string) bool {
+    found, ok := Lookup(unit)
+    return ok && found.Kind == VOLUME
+}
+
+func IsMass(unit string) bool {
+    found, ok := Lookup(unit)
+    return ok && found.Kind == MASS
+}
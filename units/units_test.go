@@ -0,0 +1,147 @@
+package units
+
+import (
+    "math"
+    "testing"
+)
+
+func TestLookup(t *testing.T) {
+    tests := []struct {
+        spelling    string
+        name        string
+        kind        string
+    }{
+        {"g", "g", MASS},
+        {"Grams", "g", MASS},
+        {"KG", "kg", MASS},
+        {"ounces", "oz", MASS},
+        {"lbs.", "lb", MASS},
+        {"tsp", "tsp", VOLUME},
+        {"Tbsp.", "tbsp", VOLUME},
+        {"tablespoons", "tbsp", VOLUME},
+        {"TBSPS", "tbsp", VOLUME},
+        // only these two are told apart by their case
+        {"T", "tbsp", VOLUME},
+        {"T.", "tbsp", VOLUME},
+        {"t", "tsp", VOLUME},
+        {" t. ", "tsp", VOLUME},
+        {"fl  oz", "fl oz", VOLUME},
+        {"fluid ounces", "fl oz", VOLUME},
+        {"Cups", "cup", VOLUME},
+        {"millilitres", "ml", VOLUME},
+        {"cloves", "clove", COUNT},
+        {"bunches", "bunch", COUNT},
+        {"tins", "can", COUNT},
+        {"inches", "inch", OTHER},
+    }
+
+    for _, test := range tests {
+        unit, ok := Lookup(test.spelling)
+        if !ok || unit.Name != test.name || unit.Kind != test.kind {
+            t.Errorf("Lookup(%q) = %s %q, %v, want %s %q", test.spelling, unit.Name, unit.Kind, ok, test.name, test.kind)
+        }
+    }
+
+    for _, spelling := range []string{"", "whole", "handfull", "spoon"} {
+        if unit, ok := Lookup(spelling); ok {
+            t.Errorf("Lookup(%q) = %s, want no unit", spelling, unit.Name)
+        }
+    }
+
+    if name := Normalize(" Whole "); name != "whole" {
+        t.Errorf("Normalize of an unknown unit = %q, want it lower cased", name)
+    }
+}
+
+func TestConvert(t *testing.T) {
+    tests := []struct {
+        value   float64
+        from    string
+        to      string
+        want    float64
+        ok      bool
+    }{
+        {1, "tbsp", "tsp", 3, true},
+        {1, "cup", "tbsp", 16, true},
+        {2, "cups", "pint", 1, true},
+        {1, "l", "ml", 1000, true},
+        {1, "cup", "ml", 236.588, true},
+        {1, "gallon", "quart", 4, true},
+        {1, "fl oz", "tbsp", 2, true},
+        {1, "lb", "oz", 16, true},
+        {1, "kg", "lb", 2.205, true},
+        {3, "cloves", "clove", 3, true},
+        {2, "pinches", "pinch", 2, true},
+        // volumes need the food's density, counts its portion
+        {1, "cup", "g", 0, false},
+        {100, "g", "ml", 0, false},
+        {1, "clove", "g", 0, false},
+        {1, "inch", "cm", 0, false},
+        {1, "whole", "whole", 1, true},
+        {1, "whole", "cup", 0, false},
+    }
+
+    for _, test := range tests {
+        got, ok := Convert(test.value, test.from, test.to)
+        if ok != test.ok || math.Abs(got-test.want) > 0.001 {
+            t.Errorf("Convert(%v, %q, %q) = %.3f, %v, want %.3f, %v", test.value, test.from, test.to, got, ok, test.want, test.ok)
+        }
+    }
+}
+
+func TestToGrams(t *testing.T) {
+    tests := []struct {
+        value   float64
+        unit    string
+        want    float64
+        ok      bool
+    }{
+        {500, "g", 500, true},
+        {250, "mg", 0.25, true},
+        {1.5, "kg", 1500, true},
+        {1, "oz", 28.350, true},
+        {2, "pounds", 907.185, true},
+        {1, "cup", 0, false},
+        {1, "tbsp", 0, false},
+        {2, "whole", 0, false},
+    }
+
+    for _, test := range tests {
+        got, ok := ToGrams(test.value, test.unit)
+        if ok != test.ok || math.Abs(got-test.want) > 0.001 {
+            t.Errorf("ToGrams(%v, %q) = %.3f, %v, want %.3f, %v", test.value, test.unit, got, ok, test.want, test.ok)
+        }
+    }
+
+    if !IsVolume("Tbsp") || IsVolume("g") || !IsMass("lbs") || IsMass("cup") {
+        t.Errorf("IsVolume or IsMass is wrong")
+    }
+}
+
+func TestSplitSize(t *testing.T) {
+    tests := []struct {
+        name    string
+        size    string
+        rest    string
+    }{
+        {"large eggs", "large", "eggs"},
+        {"Large Eggs", "large", "Eggs"},
+        {"extra large eggs", "extra large", "eggs"},
+        {"extra-large eggs", "extra large", "eggs"},
+        {"med onion", "medium", "onion"},
+        {"eggs, large", "large", "eggs"},
+        {"eggs, extra large", "extra large", "eggs"},
+        {"onions small", "small", "onions"},
+        {"eggs", "", "eggs"},
+        {"large", "large", ""},
+        {"smallmouth bass", "", "smallmouth bass"},
+        {"", "", ""},
+    }
+
+    for _, test := range tests {
+        size, rest := SplitSize(test.name)
+        if size != test.size || rest != test.rest {
+            t.Errorf("SplitSize(%q) = %q, %q, want %q, %q", test.name, size, rest, test.size, test.rest)
+        }
+    }
+}